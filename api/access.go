@@ -0,0 +1,10 @@
+package api
+
+// Visible is a SQL condition that holds when the user given as the parameter
+// param owns the thread, or it was shared with them. thread and owner are the
+// columns holding the thread id and its owner, e.g. `string.thread` and
+// `string.owner`.
+func Visible(thread string, owner string, param string) string {
+	return "(" + owner + " = " + param + " or exists(" +
+		"select 1 from thread_member where thread_member.thread = " + thread + " and thread_member.member = " + param + "))"
+}
@@ -0,0 +1,89 @@
+package analytics
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+	"github.com/orpheus/strings/api"
+	"github.com/orpheus/strings/core"
+	"github.com/orpheus/strings/infrastructure/logging"
+	"net/http"
+)
+
+// Controller serves metrics derived from the revision history of strings
+type Controller struct {
+	Interactor Interactor
+	Logger     logging.Logger
+}
+
+type Interactor interface {
+	StringDrift(user uuid.UUID, stringId uuid.UUID) (core.PriorityDrift, error)
+	ThreadDrift(user uuid.UUID, threadId uuid.UUID) ([]core.PriorityDrift, error)
+	Lifecycle(user uuid.UUID, threadId uuid.NullUUID) (core.LifecycleReport, error)
+}
+
+func (s *Controller) RegisterRoutes(router *gin.RouterGroup) {
+	analytics := router.Group("/analytics")
+	{
+		analytics.GET("/string/:id/drift", s.StringDrift)
+		analytics.GET("/thread/:id/drift", s.ThreadDrift)
+		analytics.GET("/lifecycle", s.Lifecycle)
+	}
+}
+
+// StringDrift returns the series of orders a string has held and the metrics
+// derived from it
+func (s *Controller) StringDrift(c *gin.Context) {
+	stringId, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		api.Fail(c, core.Validation(fmt.Sprintf("Failed to parse string id: %s", err.Error())))
+		return
+	}
+
+	drift, err := s.Interactor.StringDrift(api.CurrentUserId(c), stringId)
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, drift)
+}
+
+// ThreadDrift returns the drift of every string that has been part of a thread
+func (s *Controller) ThreadDrift(c *gin.Context) {
+	threadId, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		api.Fail(c, core.Validation(fmt.Sprintf("Failed to parse thread id: %s", err.Error())))
+		return
+	}
+
+	drifts, err := s.Interactor.ThreadDrift(api.CurrentUserId(c), threadId)
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, drifts)
+}
+
+// Lifecycle reports completion and fade rates across all strings, or across
+// the strings of a single thread if you pass a uuid as a `thread` query param
+func (s *Controller) Lifecycle(c *gin.Context) {
+	var threadId uuid.NullUUID
+	if thread := c.Query("thread"); thread != "" {
+		id, err := uuid.FromString(thread)
+		if err != nil {
+			api.Fail(c, core.Validation(fmt.Sprintf("Failed to parse thread id: %s", err.Error())))
+			return
+		}
+		threadId = uuid.NullUUID{UUID: id, Valid: true}
+	}
+
+	report, err := s.Interactor.Lifecycle(api.CurrentUserId(c), threadId)
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,190 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+	"github.com/orpheus/strings/api"
+	"github.com/orpheus/strings/core"
+	"github.com/orpheus/strings/infrastructure/logging"
+	"net/http"
+	"strings"
+)
+
+// Controller signs users in and out and guards every other route
+type Controller struct {
+	Interactor Interactor
+	// ProxyHeader, if set, is the header a trusted reverse proxy puts the name
+	// of the signed in user in. Requests with a bearer token ignore it.
+	ProxyHeader string
+	Logger      logging.Logger
+}
+
+type Interactor interface {
+	Register(credentials core.Credentials) (core.User, error)
+	Login(credentials core.Credentials) (core.Session, error)
+	Authenticate(secret string) (core.User, error)
+	AuthenticateProxy(username string) (core.User, error)
+	Logout(secret string) error
+	LogoutEverywhere(user uuid.UUID) error
+	CreateApiKey(user uuid.UUID, name string) (core.ApiKey, error)
+	FindApiKeys(user uuid.UUID) ([]core.Token, error)
+	RevokeApiKey(user uuid.UUID, id uuid.UUID) error
+}
+
+// RegisterPublicRoutes creates the `/auth` routes that can be called without
+// signing in
+func (s *Controller) RegisterPublicRoutes(router *gin.RouterGroup) {
+	auth := router.Group("/auth")
+	{
+		auth.POST("/register", s.Register)
+		auth.POST("/login", s.Login)
+	}
+}
+
+// RegisterRoutes creates the `/auth` routes for signed in users
+func (s *Controller) RegisterRoutes(router *gin.RouterGroup) {
+	auth := router.Group("/auth")
+	{
+		auth.POST("/logout", s.Logout)
+		auth.DELETE("/sessions", s.LogoutEverywhere)
+		auth.GET("/keys", s.FindApiKeys)
+		auth.POST("/keys", s.CreateApiKey)
+		auth.DELETE("/keys/:id", s.RevokeApiKey)
+	}
+}
+
+// Authenticate is a middleware that resolves the user behind the bearer token
+// in the `Authorization` header, or the proxy header if one is configured,
+// and rejects the request if there is none
+func (s *Controller) Authenticate(c *gin.Context) {
+	var user core.User
+	var err error
+	if secret := bearerToken(c); secret != "" || s.ProxyHeader == "" {
+		user, err = s.Interactor.Authenticate(secret)
+	} else {
+		user, err = s.Interactor.AuthenticateProxy(c.GetHeader(s.ProxyHeader))
+	}
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+
+	api.SetUser(c, user)
+	c.Next()
+}
+
+// bearerToken returns the token of an `Authorization: Bearer <token>` header
+func bearerToken(c *gin.Context) string {
+	header := c.GetHeader("Authorization")
+	if len(header) < 7 || !strings.EqualFold(header[:7], "Bearer ") {
+		return ""
+	}
+	return strings.TrimSpace(header[7:])
+}
+
+// Register creates a user from a `username` and `password`
+func (s *Controller) Register(c *gin.Context) {
+	var credentials core.Credentials
+	if err := c.ShouldBindJSON(&credentials); err != nil {
+		api.Fail(c, core.Validation(fmt.Sprintf("Failed to bind: %s", err.Error())))
+		return
+	}
+
+	user, err := s.Interactor.Register(credentials)
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
+
+// Login checks a `username` and `password` and returns a session token to
+// send as `Authorization: Bearer <token>`
+func (s *Controller) Login(c *gin.Context) {
+	var credentials core.Credentials
+	if err := c.ShouldBindJSON(&credentials); err != nil {
+		api.Fail(c, core.Validation(fmt.Sprintf("Failed to bind: %s", err.Error())))
+		return
+	}
+
+	session, err := s.Interactor.Login(credentials)
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, session)
+}
+
+// Logout revokes the token the request was made with
+func (s *Controller) Logout(c *gin.Context) {
+	err := s.Interactor.Logout(bearerToken(c))
+	if err != nil && !errors.Is(err, core.ErrTokenNotFound) {
+		api.Fail(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, true)
+}
+
+// LogoutEverywhere revokes every session of the user. API keys keep working.
+func (s *Controller) LogoutEverywhere(c *gin.Context) {
+	err := s.Interactor.LogoutEverywhere(api.CurrentUserId(c))
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, true)
+}
+
+// ApiKeyDTO binds the request body of CreateApiKey
+type ApiKeyDTO struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// CreateApiKey issues a personal API key with the `name` given in the request
+// body. The key is only shown in this response.
+func (s *Controller) CreateApiKey(c *gin.Context) {
+	var dto ApiKeyDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		api.Fail(c, core.Validation(fmt.Sprintf("Failed to bind: %s", err.Error())))
+		return
+	}
+
+	key, err := s.Interactor.CreateApiKey(api.CurrentUserId(c), dto.Name)
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, key)
+}
+
+// FindApiKeys lists the API keys of the user, without the keys themselves
+func (s *Controller) FindApiKeys(c *gin.Context) {
+	keys, err := s.Interactor.FindApiKeys(api.CurrentUserId(c))
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, keys)
+}
+
+// RevokeApiKey revokes an API key of the user by its id
+func (s *Controller) RevokeApiKey(c *gin.Context) {
+	id, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		api.Fail(c, core.Validation(fmt.Sprintf("Failed to parse key id: %s", err.Error())))
+		return
+	}
+
+	err = s.Interactor.RevokeApiKey(api.CurrentUserId(c), id)
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, true)
+}
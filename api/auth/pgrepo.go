@@ -0,0 +1,107 @@
+package auth
+
+import (
+	"context"
+	"github.com/gofrs/uuid"
+	"github.com/jackc/pgx/v4"
+	"github.com/orpheus/strings/api"
+	"github.com/orpheus/strings/core"
+	"github.com/orpheus/strings/infrastructure/logging"
+)
+
+// TokenRepository stores the hashes of sessions and API keys
+type TokenRepository struct {
+	DB     api.PgxConn
+	Logger logging.Logger
+}
+
+// columns lists the token columns in the order scanToken expects them
+const columns = "id, owner, kind, name, date_created, expires_at, last_used_at"
+
+// valid selects the tokens that are neither revoked nor expired
+const valid = "revoked_at is null and (expires_at is null or expires_at > CURRENT_TIMESTAMP)"
+
+// scanToken scans a single row selected with `columns` into a core.Token
+func scanToken(row pgx.Row) (core.Token, error) {
+	var t core.Token
+	err := row.Scan(&t.Id, &t.Owner, &t.Kind, &t.Name, &t.DateCreated, &t.ExpiresAt, &t.LastUsedAt)
+	return t, err
+}
+
+// CreateOne saves a token by its hash. Tokens without an expiry last until
+// they are revoked.
+func (r *TokenRepository) CreateOne(token core.Token, hash string) (core.Token, error) {
+	sql := "insert into auth_token (owner, kind, name, token_hash, expires_at) VALUES ($1, $2, $3, $4, $5) " +
+		"RETURNING " + columns
+	return scanToken(r.DB.QueryRow(context.Background(), sql, token.Owner, token.Kind, token.Name, hash, token.ExpiresAt))
+}
+
+// FindOwner fetches the user holding a valid token and marks the token as
+// used. The time it was last used is only kept to the minute, so that most
+// requests do not write.
+func (r *TokenRepository) FindOwner(hash string) (core.User, error) {
+	sql := "with token as (" +
+		"select id, owner, last_used_at from auth_token where token_hash = $1 and " + valid +
+		"), touched as (" +
+		"update auth_token set last_used_at = CURRENT_TIMESTAMP where id in (select id from token " +
+		"where last_used_at is null or last_used_at < CURRENT_TIMESTAMP - interval '1 minute')" +
+		") select u.id, u.username, u.date_created, u.date_modified from app_user u join token on token.owner = u.id"
+	var u core.User
+	err := r.DB.QueryRow(context.Background(), sql, hash).Scan(&u.Id, &u.Username, &u.DateCreated, &u.DateModified)
+	if err == pgx.ErrNoRows {
+		return core.User{}, core.ErrTokenNotFound
+	}
+	return u, err
+}
+
+// FindAllByKind lists the valid tokens of a kind held by a user, newest first
+func (r *TokenRepository) FindAllByKind(user uuid.UUID, kind core.TokenKind) ([]core.Token, error) {
+	sql := "select " + columns + " from auth_token where owner = $1 and kind = $2 and " + valid +
+		" order by date_created desc"
+	rows, err := r.DB.Query(context.Background(), sql, user, kind)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	tokens := []core.Token{}
+	for rows.Next() {
+		t, err := scanToken(rows)
+		if err != nil {
+			return nil, err
+		}
+		tokens = append(tokens, t)
+	}
+
+	return tokens, rows.Err()
+}
+
+// RevokeByHash revokes the token with the given hash
+func (r *TokenRepository) RevokeByHash(hash string) error {
+	sql := "update auth_token set revoked_at = CURRENT_TIMESTAMP where token_hash = $1 and " + valid
+	return r.revoke(sql, hash)
+}
+
+// RevokeById revokes a token of a user
+func (r *TokenRepository) RevokeById(user uuid.UUID, id uuid.UUID) error {
+	sql := "update auth_token set revoked_at = CURRENT_TIMESTAMP where id = $1 and owner = $2 and " + valid
+	return r.revoke(sql, id, user)
+}
+
+// RevokeAllByKind revokes every token of a kind held by a user
+func (r *TokenRepository) RevokeAllByKind(user uuid.UUID, kind core.TokenKind) error {
+	sql := "update auth_token set revoked_at = CURRENT_TIMESTAMP where owner = $1 and kind = $2 and " + valid
+	_, err := r.DB.Exec(context.Background(), sql, user, kind)
+	return err
+}
+
+func (r *TokenRepository) revoke(sql string, args ...interface{}) error {
+	tag, err := r.DB.Exec(context.Background(), sql, args...)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return core.ErrTokenNotFound
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/orpheus/strings/core"
+	"github.com/orpheus/strings/infrastructure/logging"
+	"net/http"
+)
+
+// ErrorResponse is the body of every failed request
+type ErrorResponse struct {
+	Code    core.Code   `json:"code"`
+	Message string      `json:"message"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+// statuses are the HTTP statuses of the domain error codes
+var statuses = map[core.Code]int{
+	core.CodeNotFound:        http.StatusNotFound,
+	core.CodeConflict:        http.StatusConflict,
+	core.CodeValidation:      http.StatusBadRequest,
+	core.CodeForbidden:       http.StatusForbidden,
+	core.CodeUnauthenticated: http.StatusUnauthorized,
+	core.CodeInternal:        http.StatusInternalServerError,
+}
+
+// Fail ends a request with an error. The RenderErrors middleware writes the
+// response.
+func Fail(c *gin.Context, err error) {
+	_ = c.Error(err)
+	c.Abort()
+}
+
+// Recover responds to a request whose handler panicked. gin has already
+// logged the panic.
+func Recover(c *gin.Context, recovered interface{}) {
+	c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse{
+		Code:    core.CodeInternal,
+		Message: "something went wrong",
+	})
+}
+
+// RenderErrors is a middleware that responds to requests ended with Fail.
+// Domain errors are returned to the client, anything else is logged and
+// reported as an internal error without its message.
+func RenderErrors(logger logging.Logger) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		last := c.Errors.Last()
+		if last == nil || c.Writer.Written() {
+			return
+		}
+
+		e := core.AsError(last.Err)
+		if e == nil {
+			logger.Logf("%s %s: %s\n", c.Request.Method, c.Request.URL.Path, last.Err.Error())
+			c.JSON(http.StatusInternalServerError, ErrorResponse{
+				Code:    core.CodeInternal,
+				Message: "something went wrong",
+			})
+			return
+		}
+
+		c.JSON(statuses[e.Code], ErrorResponse{
+			Code:    e.Code,
+			Message: last.Err.Error(),
+			Details: e.Details,
+		})
+	}
+}
@@ -0,0 +1,26 @@
+package api
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+	"github.com/orpheus/strings/core"
+)
+
+// userKey is the gin context key the authenticated user is stored under
+const userKey = "user"
+
+// SetUser records the user making the request
+func SetUser(c *gin.Context, user core.User) {
+	c.Set(userKey, user)
+}
+
+// CurrentUser returns the user making the request. Routes behind
+// authentication always have one.
+func CurrentUser(c *gin.Context) core.User {
+	return c.MustGet(userKey).(core.User)
+}
+
+// CurrentUserId returns the id of the user making the request
+func CurrentUserId(c *gin.Context) uuid.UUID {
+	return CurrentUser(c).Id
+}
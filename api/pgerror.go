@@ -0,0 +1,43 @@
+package api
+
+import (
+	"errors"
+	"github.com/jackc/pgconn"
+	"github.com/orpheus/strings/core"
+)
+
+// constraintErrors are the domain errors violating a constraint stands for,
+// by the name of the constraint
+var constraintErrors = map[string]error{
+	"idx_thread_name":               core.ErrThreadNameTaken,
+	"idx_string_thread_name":        core.ErrStringNameTaken,
+	"idx_app_user_username":         core.ErrUsernameTaken,
+	"idx_thread_invitation_pending": core.ErrAlreadyInvited,
+	"thread_member_pkey":            core.ErrAlreadyMember,
+	"fk_thread_id":                  core.ErrThreadNotFound,
+	"fk_string_parent":              core.ErrStringNotFound,
+	"fk_thread_member_member":       core.ErrUserNotFound,
+	"fk_thread_invitation_invitee":  core.ErrUserNotFound,
+}
+
+// PgError turns a constraint violation reported by postgres into the domain
+// error it stands for. Any other error is returned as is.
+func PgError(err error) error {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return err
+	}
+	if mapped, ok := constraintErrors[pgErr.ConstraintName]; ok {
+		return mapped
+	}
+
+	switch pgErr.Code {
+	case "23505": // unique_violation
+		return core.Conflict(pgErr.Message)
+	case "23503": // foreign_key_violation
+		return core.Validation(pgErr.Message)
+	case "23502", "23514": // not_null_violation, check_violation
+		return core.Validation(pgErr.Message)
+	}
+	return err
+}
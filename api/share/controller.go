@@ -0,0 +1,209 @@
+package share
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+	"github.com/orpheus/strings/api"
+	"github.com/orpheus/strings/core"
+	"github.com/orpheus/strings/infrastructure/logging"
+	"net/http"
+)
+
+// Controller shares threads with other users through invitations
+type Controller struct {
+	Interactor Interactor
+	Logger     logging.Logger
+}
+
+type Interactor interface {
+	FindMembers(user uuid.UUID, threadId uuid.UUID) ([]core.Member, error)
+	SetRole(user uuid.UUID, threadId uuid.UUID, member uuid.UUID, role core.Role) error
+	RemoveMember(user uuid.UUID, threadId uuid.UUID, member uuid.UUID) error
+	Invite(user uuid.UUID, threadId uuid.UUID, username string, role core.Role) (core.Invitation, error)
+	FindInvitations(user uuid.UUID, threadId uuid.UUID) ([]core.Invitation, error)
+	FindReceivedInvitations(user uuid.UUID) ([]core.Invitation, error)
+	Accept(user uuid.UUID, id uuid.UUID) error
+	Decline(user uuid.UUID, id uuid.UUID) error
+	Revoke(user uuid.UUID, id uuid.UUID) error
+}
+
+func (s *Controller) RegisterRoutes(router *gin.RouterGroup) {
+	thread := router.Group("/thread/:id")
+	{
+		thread.GET("/members", s.FindMembers)
+		thread.PUT("/members/:user", s.SetRole)
+		thread.DELETE("/members/:user", s.RemoveMember)
+		thread.GET("/invitations", s.FindInvitations)
+		thread.POST("/invitations", s.Invite)
+	}
+
+	invitation := router.Group("/invitation")
+	{
+		invitation.GET("", s.FindReceivedInvitations)
+		invitation.POST("/:id/accept", s.Accept)
+		invitation.POST("/:id/decline", s.Decline)
+		invitation.DELETE("/:id", s.Revoke)
+	}
+}
+
+// FindMembers lists the owner and members of a thread
+func (s *Controller) FindMembers(c *gin.Context) {
+	threadId, ok := parseId(c, "id", "thread")
+	if !ok {
+		return
+	}
+
+	members, err := s.Interactor.FindMembers(api.CurrentUserId(c), threadId)
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, members)
+}
+
+// RoleDTO binds the request body of SetRole
+type RoleDTO struct {
+	Role core.Role `json:"role" binding:"required"`
+}
+
+// SetRole changes the `role` of a member of a thread
+func (s *Controller) SetRole(c *gin.Context) {
+	threadId, ok := parseId(c, "id", "thread")
+	if !ok {
+		return
+	}
+	member, ok := parseId(c, "user", "user")
+	if !ok {
+		return
+	}
+
+	var dto RoleDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		api.Fail(c, core.Validation(fmt.Sprintf("Failed to bind: %s", err.Error())))
+		return
+	}
+
+	err := s.Interactor.SetRole(api.CurrentUserId(c), threadId, member, dto.Role)
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, true)
+}
+
+// RemoveMember stops sharing a thread with a member. Members can remove
+// themselves to leave a thread.
+func (s *Controller) RemoveMember(c *gin.Context) {
+	threadId, ok := parseId(c, "id", "thread")
+	if !ok {
+		return
+	}
+	member, ok := parseId(c, "user", "user")
+	if !ok {
+		return
+	}
+
+	err := s.Interactor.RemoveMember(api.CurrentUserId(c), threadId, member)
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, true)
+}
+
+// InvitationDTO binds the request body of Invite
+type InvitationDTO struct {
+	Username string    `json:"username" binding:"required"`
+	Role     core.Role `json:"role" binding:"required"`
+}
+
+// Invite offers the user with `username` a `role` on a thread
+func (s *Controller) Invite(c *gin.Context) {
+	threadId, ok := parseId(c, "id", "thread")
+	if !ok {
+		return
+	}
+
+	var dto InvitationDTO
+	if err := c.ShouldBindJSON(&dto); err != nil {
+		api.Fail(c, core.Validation(fmt.Sprintf("Failed to bind: %s", err.Error())))
+		return
+	}
+
+	invitation, err := s.Interactor.Invite(api.CurrentUserId(c), threadId, dto.Username, dto.Role)
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, invitation)
+}
+
+// FindInvitations lists the pending invitations to a thread
+func (s *Controller) FindInvitations(c *gin.Context) {
+	threadId, ok := parseId(c, "id", "thread")
+	if !ok {
+		return
+	}
+
+	invitations, err := s.Interactor.FindInvitations(api.CurrentUserId(c), threadId)
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, invitations)
+}
+
+// FindReceivedInvitations lists the pending invitations of the signed in user
+func (s *Controller) FindReceivedInvitations(c *gin.Context) {
+	invitations, err := s.Interactor.FindReceivedInvitations(api.CurrentUserId(c))
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, invitations)
+}
+
+func (s *Controller) Accept(c *gin.Context) {
+	s.answer(c, s.Interactor.Accept)
+}
+
+func (s *Controller) Decline(c *gin.Context) {
+	s.answer(c, s.Interactor.Decline)
+}
+
+// Revoke withdraws a pending invitation
+func (s *Controller) Revoke(c *gin.Context) {
+	s.answer(c, s.Interactor.Revoke)
+}
+
+func (s *Controller) answer(c *gin.Context, answer func(user uuid.UUID, id uuid.UUID) error) {
+	id, ok := parseId(c, "id", "invitation")
+	if !ok {
+		return
+	}
+
+	err := answer(api.CurrentUserId(c), id)
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, true)
+}
+
+// parseId parses a uuid path param, failing the request if it is not one
+func parseId(c *gin.Context, param string, name string) (uuid.UUID, bool) {
+	id, err := uuid.FromString(c.Param(param))
+	if err != nil {
+		api.Fail(c, core.Validation(fmt.Sprintf("Failed to parse %s id: %s", name, err.Error())))
+		return uuid.Nil, false
+	}
+	return id, true
+}
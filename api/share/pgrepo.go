@@ -0,0 +1,180 @@
+package share
+
+import (
+	"context"
+	"github.com/gofrs/uuid"
+	"github.com/jackc/pgx/v4"
+	"github.com/orpheus/strings/api"
+	"github.com/orpheus/strings/core"
+	"github.com/orpheus/strings/infrastructure/logging"
+)
+
+// Repository stores who threads are shared with and the invitations to share
+// them
+type Repository struct {
+	DB     api.PgxConn
+	Logger logging.Logger
+}
+
+// invitationColumns lists the invitation columns in the order scanInvitation
+// expects them. Queries select them from `thread_invitation i join thread t`.
+const invitationColumns = "i.id, i.thread, t.name, i.inviter, i.invitee, i.role, i.date_created, i.accepted_at, i.declined_at"
+
+func scanInvitation(row pgx.Row) (core.Invitation, error) {
+	var i core.Invitation
+	err := row.Scan(&i.Id, &i.Thread, &i.ThreadName, &i.Inviter, &i.Invitee, &i.Role, &i.DateCreated, &i.AcceptedAt, &i.DeclinedAt)
+	if err == pgx.ErrNoRows {
+		return core.Invitation{}, core.ErrInvitationNotFound
+	}
+	return i, err
+}
+
+// FindMembers lists the owner of a thread followed by its members in the order
+// they joined
+func (r *Repository) FindMembers(threadId uuid.UUID) ([]core.Member, error) {
+	sql := "select t.id, u.id, u.username, 'owner', t.date_created from thread t join app_user u on u.id = t.owner " +
+		"where t.id = $1 " +
+		"union all " +
+		"(select m.thread, u.id, u.username, m.role, m.date_created from thread_member m join app_user u on u.id = m.member " +
+		"where m.thread = $1 order by m.date_created asc)"
+	rows, err := r.DB.Query(context.Background(), sql, threadId)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	members := []core.Member{}
+	for rows.Next() {
+		var m core.Member
+		err := rows.Scan(&m.Thread, &m.User, &m.Username, &m.Role, &m.DateCreated)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, m)
+	}
+
+	return members, rows.Err()
+}
+
+// AddMember shares a thread with a user, or changes their role if it already
+// is
+func (r *Repository) AddMember(threadId uuid.UUID, user uuid.UUID, role core.Role) error {
+	sql := "insert into thread_member (thread, member, role) VALUES ($1, $2, $3) " +
+		"on conflict (thread, member) do update set role = excluded.role"
+	_, err := r.DB.Exec(context.Background(), sql, threadId, user, role)
+	return api.PgError(err)
+}
+
+// SetRole changes the role of a member
+func (r *Repository) SetRole(threadId uuid.UUID, user uuid.UUID, role core.Role) error {
+	sql := "update thread_member set role = $3 where thread = $1 and member = $2"
+	return r.exec(core.ErrMemberNotFound, sql, threadId, user, role)
+}
+
+// RemoveMember stops sharing a thread with a user
+func (r *Repository) RemoveMember(threadId uuid.UUID, user uuid.UUID) error {
+	sql := "delete from thread_member where thread = $1 and member = $2"
+	return r.exec(core.ErrMemberNotFound, sql, threadId, user)
+}
+
+// CreateInvitation saves a pending invitation
+func (r *Repository) CreateInvitation(invitation core.Invitation) (core.Invitation, error) {
+	ctx := context.Background()
+
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return core.Invitation{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	var id uuid.UUID
+	sql := "insert into thread_invitation (thread, inviter, invitee, role) VALUES ($1, $2, $3, $4) RETURNING id"
+	err = tx.QueryRow(ctx, sql, invitation.Thread, invitation.Inviter, invitation.Invitee, invitation.Role).Scan(&id)
+	if err != nil {
+		return core.Invitation{}, api.PgError(err)
+	}
+
+	sql = "select " + invitationColumns + " from thread_invitation i join thread t on t.id = i.thread where i.id = $1"
+	created, err := scanInvitation(tx.QueryRow(ctx, sql, id))
+	if err != nil {
+		return core.Invitation{}, err
+	}
+
+	return created, tx.Commit(ctx)
+}
+
+// FindPendingInvitation fetches an invitation that was neither accepted nor
+// declined
+func (r *Repository) FindPendingInvitation(id uuid.UUID) (core.Invitation, error) {
+	sql := "select " + invitationColumns + " from thread_invitation i join thread t on t.id = i.thread " +
+		"where i.id = $1 and i.accepted_at is null and i.declined_at is null and t.deleted_at is null"
+	return scanInvitation(r.DB.QueryRow(context.Background(), sql, id))
+}
+
+// FindPendingByInvitee lists the pending invitations of a user, newest first
+func (r *Repository) FindPendingByInvitee(user uuid.UUID) ([]core.Invitation, error) {
+	sql := "select " + invitationColumns + " from thread_invitation i join thread t on t.id = i.thread " +
+		"where i.invitee = $1 and i.accepted_at is null and i.declined_at is null and t.deleted_at is null " +
+		"order by i.date_created desc"
+	return r.findInvitations(sql, user)
+}
+
+// FindPendingByThread lists the pending invitations to a thread, newest first
+func (r *Repository) FindPendingByThread(threadId uuid.UUID) ([]core.Invitation, error) {
+	sql := "select " + invitationColumns + " from thread_invitation i join thread t on t.id = i.thread " +
+		"where i.thread = $1 and i.accepted_at is null and i.declined_at is null " +
+		"order by i.date_created desc"
+	return r.findInvitations(sql, threadId)
+}
+
+// AcceptInvitation marks a pending invitation as accepted
+func (r *Repository) AcceptInvitation(id uuid.UUID) error {
+	sql := "update thread_invitation set accepted_at = CURRENT_TIMESTAMP " +
+		"where id = $1 and accepted_at is null and declined_at is null"
+	return r.exec(core.ErrInvitationNotFound, sql, id)
+}
+
+// DeclineInvitation marks a pending invitation as declined
+func (r *Repository) DeclineInvitation(id uuid.UUID) error {
+	sql := "update thread_invitation set declined_at = CURRENT_TIMESTAMP " +
+		"where id = $1 and accepted_at is null and declined_at is null"
+	return r.exec(core.ErrInvitationNotFound, sql, id)
+}
+
+// DeleteInvitation withdraws a pending invitation
+func (r *Repository) DeleteInvitation(id uuid.UUID) error {
+	sql := "delete from thread_invitation where id = $1 and accepted_at is null and declined_at is null"
+	return r.exec(core.ErrInvitationNotFound, sql, id)
+}
+
+func (r *Repository) findInvitations(sql string, args ...interface{}) ([]core.Invitation, error) {
+	rows, err := r.DB.Query(context.Background(), sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	invitations := []core.Invitation{}
+	for rows.Next() {
+		i, err := scanInvitation(rows)
+		if err != nil {
+			return nil, err
+		}
+		invitations = append(invitations, i)
+	}
+
+	return invitations, rows.Err()
+}
+
+// exec runs a statement that must affect a row, returning notFound if it did
+// not
+func (r *Repository) exec(notFound error, sql string, args ...interface{}) error {
+	tag, err := r.DB.Exec(context.Background(), sql, args...)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return notFound
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package snapshot
+
+import (
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+	"github.com/orpheus/strings/api"
+	"github.com/orpheus/strings/core"
+	"github.com/orpheus/strings/infrastructure/logging"
+	"net/http"
+	"time"
+)
+
+// Controller serves views of threads and strings reconstructed from their
+// revision history
+type Controller struct {
+	Interactor Interactor
+	Logger     logging.Logger
+}
+
+type Interactor interface {
+	Snapshot(user uuid.UUID, at time.Time) (core.Snapshot, error)
+	Diff(user uuid.UUID, from time.Time, to time.Time) (core.Diff, error)
+}
+
+func (s *Controller) RegisterRoutes(router *gin.RouterGroup) {
+	router.GET("/snapshot", s.Snapshot)
+	router.GET("/diff", s.Diff)
+}
+
+// Snapshot takes an RFC 3339 timestamp as the `at` query param and returns all
+// threads and strings as they were at that instant. Defaults to now.
+func (s *Controller) Snapshot(c *gin.Context) {
+	at, err := parseTime(c.Query("at"))
+	if err != nil {
+		api.Fail(c, core.Validation(fmt.Sprintf("Failed to parse `at`: %s", err.Error())))
+		return
+	}
+
+	snapshot, err := s.Interactor.Snapshot(api.CurrentUserId(c), at)
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, snapshot)
+}
+
+// Diff takes RFC 3339 `from` and `to` query params and returns the threads
+// and strings that were added, removed, renamed, moved or reordered between
+// them. `to` defaults to now.
+func (s *Controller) Diff(c *gin.Context) {
+	if c.Query("from") == "" {
+		api.Fail(c, core.Validation("Missing `from` query param"))
+		return
+	}
+	from, err := parseTime(c.Query("from"))
+	if err != nil {
+		api.Fail(c, core.Validation(fmt.Sprintf("Failed to parse `from`: %s", err.Error())))
+		return
+	}
+	to, err := parseTime(c.Query("to"))
+	if err != nil {
+		api.Fail(c, core.Validation(fmt.Sprintf("Failed to parse `to`: %s", err.Error())))
+		return
+	}
+	if from.After(to) {
+		api.Fail(c, core.Validation("`from` must not be after `to`"))
+		return
+	}
+
+	diff, err := s.Interactor.Diff(api.CurrentUserId(c), from, to)
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, diff)
+}
+
+// parseTime parses an RFC 3339 timestamp, treating an empty value as now
+func parseTime(value string) (time.Time, error) {
+	if value == "" {
+		return time.Now(), nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
@@ -1,13 +1,13 @@
 package string
 
 import (
-	"errors"
+	"encoding/json"
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/gofrs/uuid"
+	"github.com/orpheus/strings/api"
 	"github.com/orpheus/strings/core"
 	"github.com/orpheus/strings/infrastructure/logging"
-	"log"
 	"net/http"
 )
 
@@ -20,12 +20,22 @@ type StringController struct {
 
 // StringInteractor defines the service interface the controller will usee
 type StringInteractor interface {
-	FindAll() ([]core.String, error)
-	FindAllByThread(threadId uuid.UUID) ([]core.String, error)
-	CreateOne(core.String) (core.String, error)
-	UpdateName(stringId uuid.UUID, name string) error
-	UpdateOrder(stringOrders []core.StringOrder) error
-	DeleteById(id uuid.UUID) error
+	FindAll(user uuid.UUID) ([]core.String, error)
+	FindAllByThread(user uuid.UUID, threadId uuid.UUID) ([]core.String, error)
+	CreateOne(user uuid.UUID, cs core.String) (core.String, error)
+	FindOrCreate(user uuid.UUID, cs core.String) (core.String, error)
+	UpdateName(user uuid.UUID, stringId uuid.UUID, name string) error
+	UpdateDescription(user uuid.UUID, stringId uuid.UUID, description string) error
+	Update(user uuid.UUID, stringId uuid.UUID, patch core.StringPatch) (core.String, error)
+	UpdateOrder(user uuid.UUID, stringOrders []core.StringOrder) error
+	DeleteById(user uuid.UUID, id uuid.UUID) error
+	FindHistory(user uuid.UUID, stringId uuid.UUID) ([]core.StringRevision, error)
+	Transition(user uuid.UUID, stringId uuid.UUID, to core.State) (core.String, error)
+	FindTransitions(user uuid.UUID, stringId uuid.UUID) ([]core.StateTransition, error)
+	FindTree(user uuid.UUID, id uuid.UUID) (core.StringNode, error)
+	Reparent(user uuid.UUID, stringId uuid.UUID, parent uuid.NullUUID) (core.String, error)
+	Move(user uuid.UUID, stringId uuid.UUID, threadId uuid.UUID, order int) (core.String, error)
+	Reposition(user uuid.UUID, stringId uuid.UUID, after uuid.NullUUID, before uuid.NullUUID) (core.String, error)
 }
 
 // RegisterRoutes creates a gin route grouping for the `/string` routes
@@ -35,7 +45,16 @@ func (s *StringController) RegisterRoutes(router *gin.RouterGroup) {
 		skill.GET("", s.FindAll)
 		skill.POST("", s.CreateOne)
 		skill.DELETE("/:id", s.DeleteById)
+		skill.GET("/:id/history", s.FindHistory)
+		skill.GET("/:id/transitions", s.FindTransitions)
+		skill.PUT("/:id/state", s.Transition)
+		skill.GET("/:id/tree", s.FindTree)
+		skill.PUT("/:id/parent", s.Reparent)
+		skill.PUT("/:id/move", s.Move)
+		skill.POST("/:id/reposition", s.Reposition)
+		skill.PATCH("/:id", s.Update)
 		skill.PUT("/updateName", s.UpdateName)
+		skill.PUT("/updateDescription", s.UpdateDescription)
 		skill.PUT("/updateOrder", s.UpdateOrder)
 	}
 }
@@ -45,9 +64,9 @@ func (s *StringController) RegisterRoutes(router *gin.RouterGroup) {
 func (s *StringController) FindAll(c *gin.Context) {
 	thread := c.Query("thread")
 	if thread == "" {
-		strings, err := s.Interactor.FindAll()
+		strings, err := s.Interactor.FindAll(api.CurrentUserId(c))
 		if err != nil {
-			c.JSON(http.StatusInternalServerError, err.Error())
+			api.Fail(c, err)
 			return
 		}
 		c.JSON(http.StatusOK, strings)
@@ -55,27 +74,39 @@ func (s *StringController) FindAll(c *gin.Context) {
 	}
 	threadId, err := uuid.FromString(thread)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, err.Error())
+		api.Fail(c, core.Validation(fmt.Sprintf("Failed to parse thread id: %s", err.Error())))
 		return
 	}
-	strings, err := s.Interactor.FindAllByThread(threadId)
+	strings, err := s.Interactor.FindAllByThread(api.CurrentUserId(c), threadId)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, err.Error())
+		api.Fail(c, err)
 		return
 	}
 	c.JSON(http.StatusOK, strings)
 }
 
-// CreateOne creates a single string object
+// CreateOne creates a single string object. A name already taken in the
+// thread is a conflict, unless `onConflict=merge` is passed as a query param
+// to get the string that has it instead.
 func (s *StringController) CreateOne(c *gin.Context) {
+	create := s.Interactor.CreateOne
+	switch c.Query("onConflict") {
+	case "", "fail":
+	case "merge":
+		create = s.Interactor.FindOrCreate
+	default:
+		api.Fail(c, core.Validation("`onConflict` must be `fail` or `merge`"))
+		return
+	}
+
 	var coreString core.String
 	if err := c.ShouldBindJSON(&coreString); err != nil {
-		c.JSON(http.StatusBadRequest, fmt.Sprintf("Failed to bind: %s", err.Error()))
+		api.Fail(c, core.Validation(fmt.Sprintf("Failed to bind: %s", err.Error())))
 		return
 	}
-	newString, err := s.Interactor.CreateOne(coreString)
+	newString, err := create(api.CurrentUserId(c), coreString)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, err.Error())
+		api.Fail(c, err)
 		return
 	}
 	c.IndentedJSON(http.StatusOK, newString)
@@ -86,22 +117,109 @@ func (s *StringController) CreateOne(c *gin.Context) {
 func (s *StringController) UpdateName(c *gin.Context) {
 	stringId, err := uuid.FromString(c.Query("id"))
 	if err != nil {
-		c.JSON(http.StatusBadRequest, fmt.Sprintf("Failed to parse string id: %s", err.Error()))
+		api.Fail(c, core.Validation(fmt.Sprintf("Failed to parse string id: %s", err.Error())))
 		return
 	}
 
 	newStringName := c.Query("name")
 
-	err = s.Interactor.UpdateName(stringId, newStringName)
+	err = s.Interactor.UpdateName(api.CurrentUserId(c), stringId, newStringName)
+
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, true)
+}
+
+// UpdateDescription takes a string `id` and `description` as query params to
+// update the description of that string.
+func (s *StringController) UpdateDescription(c *gin.Context) {
+	stringId, err := uuid.FromString(c.Query("id"))
+	if err != nil {
+		api.Fail(c, core.Validation(fmt.Sprintf("Failed to parse string id: %s", err.Error())))
+		return
+	}
+
+	err = s.Interactor.UpdateDescription(api.CurrentUserId(c), stringId, c.Query("description"))
 
 	if err != nil {
-		c.JSON(http.StatusBadRequest, err.Error())
+		api.Fail(c, err)
 		return
 	}
 
 	c.JSON(http.StatusOK, true)
 }
 
+// Update applies a JSON merge patch to a string. Only `name`, `description`,
+// `order` and `thread` can be patched; a null `description` clears it.
+func (s *StringController) Update(c *gin.Context) {
+	stringId, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		api.Fail(c, core.Validation(fmt.Sprintf("Failed to parse string id: %s", err.Error())))
+		return
+	}
+
+	var body map[string]json.RawMessage
+	if err := c.ShouldBindJSON(&body); err != nil {
+		api.Fail(c, core.Validation(fmt.Sprintf("Failed to bind: %s", err.Error())))
+		return
+	}
+
+	patch, err := parsePatch(body)
+	if err != nil {
+		api.Fail(c, core.Validation(err.Error()))
+		return
+	}
+
+	cs, err := s.Interactor.Update(api.CurrentUserId(c), stringId, patch)
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, cs)
+}
+
+// parsePatch turns the members of a JSON merge patch into a core.StringPatch
+func parsePatch(body map[string]json.RawMessage) (core.StringPatch, error) {
+	var patch core.StringPatch
+	for field, value := range body {
+		isNull := string(value) == "null"
+		var err error
+		switch field {
+		case "name":
+			if isNull {
+				return patch, fmt.Errorf("`name` can not be null")
+			}
+			err = json.Unmarshal(value, &patch.Name)
+		case "description":
+			description := ""
+			if !isNull {
+				err = json.Unmarshal(value, &description)
+			}
+			patch.Description = &description
+		case "order":
+			if isNull {
+				return patch, fmt.Errorf("`order` can not be null")
+			}
+			err = json.Unmarshal(value, &patch.Order)
+		case "thread":
+			if isNull {
+				return patch, fmt.Errorf("`thread` can not be null")
+			}
+			err = json.Unmarshal(value, &patch.Thread)
+		default:
+			return patch, fmt.Errorf("`%s` can not be patched", field)
+		}
+		if err != nil {
+			return patch, fmt.Errorf("Failed to parse `%s`: %s", field, err.Error())
+		}
+	}
+	return patch, nil
+}
+
 // StringOrderDTO is needed to bind the request body. The core.StringOrder struct defines
 // `Id` as an uuid.UUID which gin cannot bind. So I needed to create a DTO struct to bind
 // to and then convert to the core struct
@@ -110,27 +228,38 @@ type StringOrderDTO struct {
 	Order int    `json:"order"`
 }
 
-// UpdateOrder takes a list of id and order values and updates the strings
+// UpdateOrder takes a list of id and order values covering every string of
+// one thread, or every minor string of one parent, and renumbers them. Orders
+// must run from 0 without gaps or repeats.
 func (s *StringController) UpdateOrder(c *gin.Context) {
 	var stringOrderDTOs []StringOrderDTO
 	if err := c.ShouldBind(&stringOrderDTOs); err != nil {
-		c.AbortWithError(http.StatusBadRequest, errors.New(fmt.Sprintf("Failed to bind `stringOrders`: %s", err.Error())))
+		api.Fail(c, core.Validation(fmt.Sprintf("Failed to bind `stringOrders`: %s", err.Error())))
 		return
 	}
 
 	var stringOrders []core.StringOrder
+	var problems []core.OrderProblem
 	for _, s := range stringOrderDTOs {
-		id, _ := uuid.FromString(s.Id)
+		id, err := uuid.FromString(s.Id)
+		if err != nil {
+			problems = append(problems, core.OrderProblem{Id: s.Id, Problem: "not a valid id"})
+			continue
+		}
 		stringOrders = append(stringOrders, core.StringOrder{
 			Id:    id,
 			Order: s.Order,
 		})
 	}
+	if len(problems) > 0 {
+		api.Fail(c, core.ErrStringOrders.WithDetails(problems))
+		return
+	}
 
-	err := s.Interactor.UpdateOrder(stringOrders)
+	err := s.Interactor.UpdateOrder(api.CurrentUserId(c), stringOrders)
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, fmt.Sprintf("Failed to update string order: %s", err.Error()))
+		api.Fail(c, err)
 		return
 	}
 
@@ -142,15 +271,186 @@ func (s *StringController) UpdateOrder(c *gin.Context) {
 func (s *StringController) DeleteById(c *gin.Context) {
 	stringId, err := uuid.FromString(c.Param("id"))
 	if err != nil {
-		log.Fatalf("failed to parse UUID %q: %v", s, err)
+		api.Fail(c, core.Validation(fmt.Sprintf("Failed to parse string id: %s", err.Error())))
+		return
 	}
 
-	err = s.Interactor.DeleteById(stringId)
+	err = s.Interactor.DeleteById(api.CurrentUserId(c), stringId)
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, err.Error())
+		api.Fail(c, err)
 		return
 	}
 
 	c.IndentedJSON(http.StatusOK, true)
 }
+
+// FindHistory returns every recorded revision of a string, oldest first, so
+// clients can see how a string changed over time
+func (s *StringController) FindHistory(c *gin.Context) {
+	stringId, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		api.Fail(c, core.Validation(fmt.Sprintf("Failed to parse string id: %s", err.Error())))
+		return
+	}
+
+	revisions, err := s.Interactor.FindHistory(api.CurrentUserId(c), stringId)
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, revisions)
+}
+
+// StateDTO binds the request body of a lifecycle transition
+type StateDTO struct {
+	State core.State `json:"state" binding:"required"`
+}
+
+// Transition moves a string to the `state` given in the request body
+func (s *StringController) Transition(c *gin.Context) {
+	stringId, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		api.Fail(c, core.Validation(fmt.Sprintf("Failed to parse string id: %s", err.Error())))
+		return
+	}
+
+	var state StateDTO
+	if err := c.ShouldBindJSON(&state); err != nil {
+		api.Fail(c, core.Validation(fmt.Sprintf("Failed to bind: %s", err.Error())))
+		return
+	}
+
+	cs, err := s.Interactor.Transition(api.CurrentUserId(c), stringId, state.State)
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, cs)
+}
+
+// FindTransitions returns every lifecycle transition of a string, oldest first
+func (s *StringController) FindTransitions(c *gin.Context) {
+	stringId, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		api.Fail(c, core.Validation(fmt.Sprintf("Failed to parse string id: %s", err.Error())))
+		return
+	}
+
+	transitions, err := s.Interactor.FindTransitions(api.CurrentUserId(c), stringId)
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, transitions)
+}
+
+// FindTree returns a string with its minor strings nested under it
+func (s *StringController) FindTree(c *gin.Context) {
+	stringId, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		api.Fail(c, core.Validation(fmt.Sprintf("Failed to parse string id: %s", err.Error())))
+		return
+	}
+
+	tree, err := s.Interactor.FindTree(api.CurrentUserId(c), stringId)
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tree)
+}
+
+// ParentDTO binds the request body of a reparent. A null `parent` moves the
+// string to the top level of its thread.
+type ParentDTO struct {
+	Parent uuid.NullUUID `json:"parent"`
+}
+
+// Reparent nests a string, along with its minor strings, under the `parent`
+// given in the request body
+func (s *StringController) Reparent(c *gin.Context) {
+	stringId, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		api.Fail(c, core.Validation(fmt.Sprintf("Failed to parse string id: %s", err.Error())))
+		return
+	}
+
+	var parent ParentDTO
+	if err := c.ShouldBindJSON(&parent); err != nil {
+		api.Fail(c, core.Validation(fmt.Sprintf("Failed to bind: %s", err.Error())))
+		return
+	}
+
+	cs, err := s.Interactor.Reparent(api.CurrentUserId(c), stringId, parent.Parent)
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, cs)
+}
+
+// MoveDTO binds the request body of a move between threads
+type MoveDTO struct {
+	Thread uuid.UUID `json:"thread" binding:"required"`
+	Order  int       `json:"order"`
+}
+
+// Move moves a string to the `thread` and `order` given in the request body
+func (s *StringController) Move(c *gin.Context) {
+	stringId, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		api.Fail(c, core.Validation(fmt.Sprintf("Failed to parse string id: %s", err.Error())))
+		return
+	}
+
+	var move MoveDTO
+	if err := c.ShouldBindJSON(&move); err != nil {
+		api.Fail(c, core.Validation(fmt.Sprintf("Failed to bind: %s", err.Error())))
+		return
+	}
+
+	cs, err := s.Interactor.Move(api.CurrentUserId(c), stringId, move.Thread, move.Order)
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, cs)
+}
+
+// RepositionDTO binds the request body of a reposition. Either neighbor may be
+// left out to put the string first or last next to the other.
+type RepositionDTO struct {
+	After  uuid.NullUUID `json:"after"`
+	Before uuid.NullUUID `json:"before"`
+}
+
+// Reposition puts a string right after the string `after`, right before the
+// string `before`, or between the two
+func (s *StringController) Reposition(c *gin.Context) {
+	stringId, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		api.Fail(c, core.Validation(fmt.Sprintf("Failed to parse string id: %s", err.Error())))
+		return
+	}
+
+	var reposition RepositionDTO
+	if err := c.ShouldBindJSON(&reposition); err != nil {
+		api.Fail(c, core.Validation(fmt.Sprintf("Failed to bind: %s", err.Error())))
+		return
+	}
+
+	cs, err := s.Interactor.Reposition(api.CurrentUserId(c), stringId, reposition.After, reposition.Before)
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, cs)
+}
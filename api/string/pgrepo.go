@@ -2,12 +2,12 @@ package string
 
 import (
 	"context"
-	"fmt"
 	"github.com/gofrs/uuid"
+	"github.com/jackc/pgx/v4"
 	"github.com/orpheus/strings/api"
 	"github.com/orpheus/strings/core"
 	"github.com/orpheus/strings/infrastructure/logging"
-	"log"
+	"time"
 )
 
 type StringRepository struct {
@@ -15,8 +15,32 @@ type StringRepository struct {
 	Logger logging.Logger
 }
 
-func (s *StringRepository) FindAll() ([]core.String, error) {
-	rows, err := s.DB.Query(context.Background(), "select * from string")
+// subtree is a recursive query selecting the id of the string given as $1,
+// if the user given as $2 can see it, and the ids of all strings nested under
+// it
+var subtree = "select id from string where id = $1 and " + api.Visible("string.thread", "string.owner", "$2") + " and deleted_at is null " +
+	"union all " +
+	"select s.id from string s join subtree on s.parent = subtree.id where s.deleted_at is null"
+
+// position counts the siblings ranked before a string, which is its order.
+// It must be selected from the `string` table without an alias.
+const position = "(select count(*) from string sibling where sibling.thread = string.thread " +
+	"and sibling.parent is not distinct from string.parent and sibling.deleted_at is null " +
+	"and (sibling.rank, sibling.id) < (string.rank, string.id))"
+
+// columns lists the string columns in the order scanString expects them
+const columns = "id, name, " + position + ", thread, owner, parent, description, kind, state, date_created, date_modified, deleted_at"
+
+// scanString scans a single row selected with `columns` into a core.String
+func scanString(row pgx.Row) (core.String, error) {
+	var r core.String
+	err := row.Scan(&r.Id, &r.Name, &r.Order, &r.Thread, &r.Owner, &r.Parent, &r.Description, &r.Kind, &r.State, &r.DateCreated, &r.DateModified, &r.DeletedAt)
+	return r, err
+}
+
+func (s *StringRepository) FindAll(user uuid.UUID) ([]core.String, error) {
+	sql := "select " + columns + " from string where " + api.Visible("string.thread", "string.owner", "$1") + " and deleted_at is null"
+	rows, err := s.DB.Query(context.Background(), sql, user)
 	if err != nil {
 		return nil, err
 	}
@@ -24,10 +48,9 @@ func (s *StringRepository) FindAll() ([]core.String, error) {
 
 	var strings []core.String
 	for rows.Next() {
-		var r core.String
-		err := rows.Scan(&r.Id, &r.Name, &r.Order, &r.Thread, &r.Description, &r.DateCreated, &r.DateModified)
+		r, err := scanString(rows)
 		if err != nil {
-			log.Fatal(err)
+			return nil, err
 		}
 		strings = append(strings, r)
 	}
@@ -45,9 +68,10 @@ func (s *StringRepository) FindAll() ([]core.String, error) {
 	return strings, nil
 }
 
-func (s *StringRepository) FindAllByThread(threadId uuid.UUID) ([]core.String, error) {
-	sql := "select * from string where thread = $1 order by \"order\" asc"
-	rows, err := s.DB.Query(context.Background(), sql, threadId)
+func (s *StringRepository) FindAllByThread(user uuid.UUID, threadId uuid.UUID) ([]core.String, error) {
+	sql := "select " + columns + " from string where thread = $1 and " + api.Visible("string.thread", "string.owner", "$2") +
+		" and deleted_at is null order by rank, id"
+	rows, err := s.DB.Query(context.Background(), sql, threadId, user)
 	if err != nil {
 		return nil, err
 	}
@@ -55,10 +79,9 @@ func (s *StringRepository) FindAllByThread(threadId uuid.UUID) ([]core.String, e
 
 	var strings []core.String
 	for rows.Next() {
-		var r core.String
-		err := rows.Scan(&r.Id, &r.Name, &r.Order, &r.Thread, &r.Description, &r.DateCreated, &r.DateModified)
+		r, err := scanString(rows)
 		if err != nil {
-			log.Fatal(err)
+			return nil, err
 		}
 		strings = append(strings, r)
 	}
@@ -76,41 +99,222 @@ func (s *StringRepository) FindAllByThread(threadId uuid.UUID) ([]core.String, e
 	return strings, nil
 }
 
-func (s *StringRepository) CreateOne(coreString core.String) (core.String, error) {
-	sql := "insert into string (name, \"order\", thread, description) " +
-		"VALUES ($1, $2, $3, $4) " +
-		"RETURNING *"
+// FindByName finds the string of a thread whose name matches the given one,
+// ignoring case and whitespace the way the unique index on names does
+func (s *StringRepository) FindByName(user uuid.UUID, threadId uuid.UUID, name string) (core.String, error) {
+	sql := "select " + columns + " from string where thread = $1 and string_name_key(name) = string_name_key($2) and " +
+		api.Visible("string.thread", "string.owner", "$3") + " and deleted_at is null"
+	cs, err := scanString(s.DB.QueryRow(context.Background(), sql, threadId, name, user))
+	if err == pgx.ErrNoRows {
+		return core.String{}, core.ErrStringNotFound
+	}
+	return cs, err
+}
+
+// CreateOne saves a new string made by user, ranked last among its siblings.
+// The order it was given is ignored. Strings belong to the owner of their
+// thread.
+func (s *StringRepository) CreateOne(user uuid.UUID, coreString core.String) (core.String, error) {
+	ctx := context.Background()
 
-	var cs core.String
-	err := s.DB.QueryRow(context.Background(), sql, coreString.Name, coreString.Order, coreString.Thread, coreString.Description).
-		Scan(&cs.Id, &cs.Name, &cs.Order, &cs.Thread, &cs.Description, &cs.DateCreated, &cs.DateModified)
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return core.String{}, err
+	}
+	defer tx.Rollback(ctx)
 
-	return cs, err
+	rank, err := rankAt(ctx, tx, coreString.Thread, coreString.Parent, -1, uuid.Nil)
+	if err != nil {
+		return core.String{}, err
+	}
+
+	sql := "insert into string (name, rank, thread, parent, description, kind, state, owner) " +
+		"VALUES ($1, $2, $3, $4, $5, $6, $7, (select owner from thread where id = $3)) " +
+		"RETURNING " + columns
+
+	cs, err := scanString(tx.QueryRow(ctx, sql, coreString.Name, rank, coreString.Thread, coreString.Parent,
+		coreString.Description, coreString.Kind, coreString.State))
+	if err != nil {
+		return core.String{}, api.PgError(err)
+	}
+
+	err = insertRevision(ctx, tx, user, core.RevisionCreate, nil, &cs)
+	if err != nil {
+		return core.String{}, err
+	}
+
+	return cs, tx.Commit(ctx)
 }
 
-func (s *StringRepository) DeleteById(id uuid.UUID) error {
-	// TODO(Check if exists first, so you can let client know he did what was expected)
-	sql := "delete from string where id = $1"
-	_, err := s.DB.Exec(context.Background(), sql, id)
-	return err
+// DeleteById moves a string to the trash. Minor strings go to the trash
+// together with the string they are nested under and the siblings after it
+// move up.
+func (s *StringRepository) DeleteById(user uuid.UUID, id uuid.UUID) error {
+	ctx := context.Background()
+
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	cs, err := findForUpdate(ctx, tx, user, id)
+	if err != nil {
+		return err
+	}
+	orders, err := lockSiblings(ctx, tx, core.Siblings{Thread: cs.Thread, Parent: cs.Parent})
+	if err != nil {
+		return err
+	}
+
+	sql := "with recursive subtree as (" + subtree + ") " +
+		"update string set deleted_at = CURRENT_TIMESTAMP where id in (select id from subtree) RETURNING " + columns
+	err = deleteWithRevisions(ctx, tx, user, sql, id, user)
+	if err != nil {
+		return err
+	}
+
+	err = orders.recordShifts(ctx, tx, user, id)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
 }
 
-func (s *StringRepository) DeleteAllByThread(threadId uuid.UUID) error {
-	sql := "delete from string where thread = $1"
-	_, err := s.DB.Exec(context.Background(), sql, threadId)
-	return err
+// DeleteAllByThread moves every string of a thread to the trash, marking them
+// as deleted at the same time as the thread
+func (s *StringRepository) DeleteAllByThread(user uuid.UUID, threadId uuid.UUID, deletedAt time.Time) error {
+	ctx := context.Background()
+
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	sql := "update string set deleted_at = $2 where thread = $1 and owner = $3 and deleted_at is null RETURNING " + columns
+	err = deleteWithRevisions(ctx, tx, user, sql, threadId, deletedAt, user)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
 }
 
-func (s *StringRepository) UpdateName(stringId uuid.UUID, name string) error {
-	sql := "update string set name = $1 where id = $2"
-	res, err := s.DB.Exec(context.Background(), sql, name, stringId)
-	fmt.Println("UpdateName Exec: ", res.String())
+// Update applies a patch to a string. Changing the thread moves the string,
+// along with its minor strings, to the top level of the new thread at the
+// patched order, or last if no order is given. A single revision is recorded
+// for the whole patch and patches that change nothing are not saved.
+func (s *StringRepository) Update(user uuid.UUID, stringId uuid.UUID, patch core.StringPatch) (core.String, error) {
+	ctx := context.Background()
 
-	return err
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return core.String{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	before, err := findForUpdate(ctx, tx, user, stringId)
+	if err != nil {
+		return core.String{}, err
+	}
+
+	after := before
+	if patch.Name != nil {
+		after.Name = *patch.Name
+	}
+	if patch.Description != nil {
+		after.Description = *patch.Description
+	}
+
+	moves := patch.Thread != nil && *patch.Thread != before.Thread
+	reorders := patch.Order != nil && *patch.Order != before.Order
+	var orders siblingOrders
+	if moves || reorders {
+		groups := []core.Siblings{{Thread: before.Thread, Parent: before.Parent}}
+		if moves {
+			groups = append(groups, core.Siblings{Thread: *patch.Thread})
+		}
+		orders, err = lockSiblings(ctx, tx, groups...)
+		if err != nil {
+			return core.String{}, err
+		}
+	}
+
+	// a nil rank keeps the string where it is
+	var rank *string
+	if moves {
+		order := -1
+		if patch.Order != nil {
+			order = *patch.Order
+		}
+		moved, err := relocate(ctx, tx, user, before, *patch.Thread, order)
+		if err != nil {
+			return core.String{}, err
+		}
+		rank = &moved
+		after.Thread = *patch.Thread
+		after.Parent = uuid.NullUUID{}
+	} else if reorders {
+		reordered, err := rankAt(ctx, tx, before.Thread, before.Parent, *patch.Order, before.Id)
+		if err != nil {
+			return core.String{}, err
+		}
+		rank = &reordered
+	}
+
+	if after == before && rank == nil {
+		return before, nil
+	}
+
+	sql := "update string set name = $1, description = $2, rank = coalesce($3, rank), thread = $4, parent = $5, " +
+		"owner = (select owner from thread where id = $4), date_modified = CURRENT_TIMESTAMP where id = $6 RETURNING " + columns
+	after, err = scanString(tx.QueryRow(ctx, sql, after.Name, after.Description, rank, after.Thread, after.Parent, stringId))
+	if err != nil {
+		return core.String{}, api.PgError(err)
+	}
+
+	err = insertRevision(ctx, tx, user, patchAction(before, after), &before, &after)
+	if err != nil {
+		return core.String{}, err
+	}
+
+	err = orders.recordShifts(ctx, tx, user, stringId)
+	if err != nil {
+		return core.String{}, err
+	}
+
+	return after, tx.Commit(ctx)
+}
+
+// patchAction names the revision of a patch after the single change it made,
+// or `update` if it changed more than one thing
+func patchAction(before core.String, after core.String) core.RevisionAction {
+	var actions []core.RevisionAction
+	if before.Name != after.Name {
+		actions = append(actions, core.RevisionRename)
+	}
+	if before.Description != after.Description {
+		actions = append(actions, core.RevisionDescribe)
+	}
+	if before.Thread != after.Thread {
+		actions = append(actions, core.RevisionMove)
+	} else if before.Order != after.Order {
+		actions = append(actions, core.RevisionReorder)
+	}
+
+	if len(actions) == 1 {
+		return actions[0]
+	}
+	return core.RevisionUpdate
 }
 
-func (s *StringRepository) UpdateOrder(stringOrders []core.StringOrder) error {
-	ctx := context.Background() // is it safe to share context with multiple functions?
+// UpdateOrder ranks a whole group of siblings anew from the given orders,
+// which must number every one of them from 0. A revision is recorded for
+// every string whose order changed.
+func (s *StringRepository) UpdateOrder(user uuid.UUID, stringOrders []core.StringOrder) error {
+	ctx := context.Background()
 
 	tx, err := s.DB.Begin(ctx)
 	if err != nil {
@@ -121,19 +325,887 @@ func (s *StringRepository) UpdateOrder(stringOrders []core.StringOrder) error {
 	// the tx commits successfully, this is a no-op
 	defer tx.Rollback(ctx)
 
-	sql := "update string set \"order\" = $1 where id = $2"
+	// orders are counted from the ranks of the siblings, so every rank is
+	// changed before any order is read back
+	befores := make([]core.String, len(stringOrders))
+	for i, stringOrder := range stringOrders {
+		befores[i], err = findForUpdate(ctx, tx, user, stringOrder.Id)
+		if err != nil {
+			return err
+		}
+	}
 
+	ranks := core.SpreadRanks(len(stringOrders))
+	sql := "update string set rank = $1 where id = $2"
 	for _, stringOrder := range stringOrders {
-		_, err = tx.Exec(context.Background(), sql, stringOrder.Order, stringOrder.Id)
+		_, err = tx.Exec(ctx, sql, ranks[stringOrder.Order], stringOrder.Id)
+		if err != nil {
+			return err
+		}
+	}
+
+	sql = "update string set date_modified = CURRENT_TIMESTAMP where id = $1 RETURNING " + columns
+	for i := range befores {
+		if befores[i].Order == stringOrders[i].Order {
+			continue
+		}
+
+		after, err := scanString(tx.QueryRow(ctx, sql, befores[i].Id))
 		if err != nil {
 			return err
 		}
+
+		err = insertRevision(ctx, tx, user, core.RevisionReorder, &befores[i], &after)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// FindDeleted returns every string in the trash, most recently deleted first
+func (s *StringRepository) FindDeleted(user uuid.UUID) ([]core.String, error) {
+	sql := "select " + columns + " from string where owner = $1 and deleted_at is not null order by deleted_at desc, rank, id"
+	return queryStrings(context.Background(), s.DB, sql, user)
+}
+
+// RestoreById takes a string out of the trash together with the minor strings
+// that were deleted along with it. Its thread and parent must not be in the
+// trash.
+func (s *StringRepository) RestoreById(user uuid.UUID, id uuid.UUID) ([]core.String, error) {
+	ctx := context.Background()
+
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	sql := "select " + columns + " from string where id = $1 and owner = $2 and deleted_at is not null for update"
+	cs, err := scanString(tx.QueryRow(ctx, sql, id, user))
+	if err == pgx.ErrNoRows {
+		return nil, core.ErrStringNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var threadDeleted, parentDeleted bool
+	sql = "select exists(select 1 from thread where id = $1 and deleted_at is not null), " +
+		"exists(select 1 from string where id = $2 and deleted_at is not null)"
+	err = tx.QueryRow(ctx, sql, cs.Thread, cs.Parent).Scan(&threadDeleted, &parentDeleted)
+	if err != nil {
+		return nil, err
+	}
+	if threadDeleted {
+		return nil, core.ErrRestoreThread
+	}
+	if parentDeleted {
+		return nil, core.ErrRestoreParent
+	}
+
+	orders, err := lockSiblings(ctx, tx, core.Siblings{Thread: cs.Thread, Parent: cs.Parent})
+	if err != nil {
+		return nil, err
+	}
+
+	sql = "with recursive deleted as (" +
+		"select id from string where id = $1 " +
+		"union all " +
+		"select s.id from string s join deleted on s.parent = deleted.id where s.deleted_at = $2" +
+		") select " + columns + " from string where id in (select id from deleted) for update"
+	restored, err := restoreWithRevisions(ctx, tx, user, sql, id, cs.DeletedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	err = orders.recordShifts(ctx, tx, user, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return restored, tx.Commit(ctx)
+}
+
+// RestoreAllByThread takes the strings that were deleted along with a thread
+// out of the trash
+func (s *StringRepository) RestoreAllByThread(user uuid.UUID, threadId uuid.UUID, deletedAt time.Time) ([]core.String, error) {
+	ctx := context.Background()
+
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	sql := "select " + columns + " from string where thread = $1 and deleted_at = $2 and owner = $3 for update"
+	restored, err := restoreWithRevisions(ctx, tx, user, sql, threadId, deletedAt, user)
+	if err != nil {
+		return nil, err
+	}
+
+	return restored, tx.Commit(ctx)
+}
+
+// Purge permanently deletes the strings of every user that went to the trash
+// before the given time. Revisions are kept.
+func (s *StringRepository) Purge(before time.Time) (int64, error) {
+	sql := "delete from string where deleted_at < $1 and not exists(" +
+		"select 1 from string c where c.parent = string.id and (c.deleted_at is null or c.deleted_at >= $1))"
+	tag, err := s.DB.Exec(context.Background(), sql, before)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// restoreWithRevisions clears `deleted_at` on every string selected by the
+// query and records a restore revision for each of them
+func restoreWithRevisions(ctx context.Context, db api.PgxConn, user uuid.UUID, sql string, args ...interface{}) ([]core.String, error) {
+	deleted, err := queryStrings(ctx, db, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	restored := make([]core.String, 0, len(deleted))
+	sql = "update string set deleted_at = null, date_modified = CURRENT_TIMESTAMP where id = $1 RETURNING " + columns
+	for i := range deleted {
+		after, err := scanString(db.QueryRow(ctx, sql, deleted[i].Id))
+		if err != nil {
+			return nil, api.PgError(err)
+		}
+		err = insertRevision(ctx, db, user, core.RevisionRestore, &deleted[i], &after)
+		if err != nil {
+			return nil, err
+		}
+		restored = append(restored, after)
+	}
+
+	return restored, nil
+}
+
+func (s *StringRepository) FindById(user uuid.UUID, id uuid.UUID) (core.String, error) {
+	sql := "select " + columns + " from string where id = $1 and " + api.Visible("string.thread", "string.owner", "$2") + " and deleted_at is null"
+	cs, err := scanString(s.DB.QueryRow(context.Background(), sql, id, user))
+	if err == pgx.ErrNoRows {
+		return core.String{}, core.ErrStringNotFound
+	}
+	return cs, err
+}
+
+// FindSubtree returns a string and every string nested under it
+func (s *StringRepository) FindSubtree(user uuid.UUID, id uuid.UUID) ([]core.String, error) {
+	sql := "with recursive subtree as (" + subtree + ") " +
+		"select " + columns + " from string where id in (select id from subtree) order by rank, id"
+	return queryStrings(context.Background(), s.DB, sql, id, user)
+}
+
+// FindAncestorIds returns the ids of a string and every string above it,
+// starting with the string itself
+func (s *StringRepository) FindAncestorIds(user uuid.UUID, id uuid.UUID) ([]uuid.UUID, error) {
+	sql := "with recursive ancestors as (" +
+		"select id, parent, 0 as depth from string where id = $1 and " + api.Visible("string.thread", "string.owner", "$2") + " " +
+		"union all " +
+		"select s.id, s.parent, a.depth + 1 from string s join ancestors a on s.id = a.parent" +
+		") select id from ancestors order by depth asc"
+	rows, err := s.DB.Query(context.Background(), sql, id, user)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var ids []uuid.UUID
+	for rows.Next() {
+		var ancestor uuid.UUID
+		if err := rows.Scan(&ancestor); err != nil {
+			return nil, err
+		}
+		ids = append(ids, ancestor)
+	}
+
+	return ids, rows.Err()
+}
+
+// UpdateParent nests a string and its minor strings under a new parent, or
+// makes it a top level string of its thread if parent is null. The string is
+// placed last among its new siblings and the gap it leaves among its old
+// siblings is closed.
+func (s *StringRepository) UpdateParent(user uuid.UUID, stringId uuid.UUID, parent uuid.NullUUID) error {
+	ctx := context.Background()
+
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	before, err := findForUpdate(ctx, tx, user, stringId)
+	if err != nil {
+		return err
+	}
+	if before.Parent == parent {
+		return nil
+	}
+
+	// the string goes last among its new siblings, so only its old siblings
+	// shift
+	orders, err := lockSiblings(ctx, tx, core.Siblings{Thread: before.Thread, Parent: before.Parent})
+	if err != nil {
+		return err
+	}
+
+	rank, err := rankAt(ctx, tx, before.Thread, parent, -1, before.Id)
+	if err != nil {
+		return err
+	}
+
+	sql := "update string set parent = $1, rank = $2, date_modified = CURRENT_TIMESTAMP where id = $3 RETURNING " + columns
+	after, err := scanString(tx.QueryRow(ctx, sql, parent, rank, stringId))
+	if err != nil {
+		return api.PgError(err)
+	}
+
+	err = insertRevision(ctx, tx, user, core.RevisionReparent, &before, &after)
+	if err != nil {
+		return err
+	}
+
+	err = orders.recordShifts(ctx, tx, user, stringId)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// Move moves a string and its minor strings to the top level of another
+// thread at the given order, all in one transaction. Orders past the end of
+// the destination append the string. The siblings it leaves and joins get a
+// reorder revision if their order shifted.
+func (s *StringRepository) Move(user uuid.UUID, stringId uuid.UUID, threadId uuid.UUID, order int) error {
+	ctx := context.Background()
+
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	before, err := findForUpdate(ctx, tx, user, stringId)
+	if err != nil {
+		return err
+	}
+	orders, err := lockSiblings(ctx, tx, core.Siblings{Thread: before.Thread, Parent: before.Parent}, core.Siblings{Thread: threadId})
+	if err != nil {
+		return err
+	}
+
+	rank, err := relocate(ctx, tx, user, before, threadId, order)
+	if err != nil {
+		return err
+	}
+
+	sql := "update string set thread = $1, owner = (select owner from thread where id = $1), parent = null, rank = $2, " +
+		"date_modified = CURRENT_TIMESTAMP where id = $3 RETURNING " + columns
+	after, err := scanString(tx.QueryRow(ctx, sql, threadId, rank, stringId))
+	if err != nil {
+		return api.PgError(err)
+	}
+
+	err = insertRevision(ctx, tx, user, core.RevisionMove, &before, &after)
+	if err != nil {
+		return err
 	}
 
-	err = tx.Commit(ctx)
+	err = orders.recordShifts(ctx, tx, user, stringId)
 	if err != nil {
 		return err
 	}
 
+	return tx.Commit(ctx)
+}
+
+// Reposition puts a string right after the string after, right before the
+// string before, or between the two, by updating its rank alone unless the
+// neighbors have no room left between them, in which case the ranks of the
+// siblings are spread out first. Either neighbor may be null but not both. A reorder revision is recorded for it if
+// its order changed, and for every sibling it shifted.
+func (s *StringRepository) Reposition(user uuid.UUID, stringId uuid.UUID, after uuid.NullUUID, before uuid.NullUUID) error {
+	ctx := context.Background()
+
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	cs, err := findForUpdate(ctx, tx, user, stringId)
+	if err != nil {
+		return err
+	}
+	orders, err := lockSiblings(ctx, tx, core.Siblings{Thread: cs.Thread, Parent: cs.Parent})
+	if err != nil {
+		return err
+	}
+
+	rank, err := rankBetween(ctx, tx, cs, after, before)
+	if err == core.ErrRankOrder {
+		// the neighbors have no room between them or share a rank
+		err = spreadRanks(ctx, tx, cs.Thread, cs.Parent)
+		if err != nil {
+			return err
+		}
+		rank, err = rankBetween(ctx, tx, cs, after, before)
+	}
+	if err == core.ErrRankOrder {
+		return core.ErrNeighbors
+	}
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, "update string set rank = $1 where id = $2", rank, stringId)
+	if err != nil {
+		return err
+	}
+
+	repositioned, err := findForUpdate(ctx, tx, user, stringId)
+	if err != nil {
+		return err
+	}
+	if repositioned.Order != cs.Order {
+		sql := "update string set date_modified = CURRENT_TIMESTAMP where id = $1 RETURNING " + columns
+		repositioned, err = scanString(tx.QueryRow(ctx, sql, stringId))
+		if err != nil {
+			return err
+		}
+		err = insertRevision(ctx, tx, user, core.RevisionReorder, &cs, &repositioned)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = orders.recordShifts(ctx, tx, user, stringId)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// rankBetween returns a rank between the strings after and before. When only
+// one of them is given, the other is the sibling next to it, leaving out cs.
+func rankBetween(ctx context.Context, db api.PgxConn, cs core.String, after uuid.NullUUID, before uuid.NullUUID) (string, error) {
+	var low, high string
+	var err error
+	if after.Valid {
+		low, err = findRank(ctx, db, "select rank from string where id = $1 and deleted_at is null", after.UUID)
+		if err != nil {
+			return "", err
+		}
+	}
+	if before.Valid {
+		high, err = findRank(ctx, db, "select rank from string where id = $1 and deleted_at is null", before.UUID)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	siblings := "select rank from string where thread = $1 and parent is not distinct from $2 and id <> $3 " +
+		"and deleted_at is null "
+	if after.Valid && !before.Valid {
+		high, err = findRank(ctx, db, siblings+"and (rank, id) > ($4, $5) order by rank, id limit 1",
+			cs.Thread, cs.Parent, cs.Id, low, after.UUID)
+	}
+	if before.Valid && !after.Valid {
+		low, err = findRank(ctx, db, siblings+"and (rank, id) < ($4, $5) order by rank desc, id desc limit 1",
+			cs.Thread, cs.Parent, cs.Id, high, before.UUID)
+	}
+	if err == core.ErrStringNotFound {
+		// there is no sibling on that side, so it is the start or end
+		err = nil
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return core.RankBetween(low, high)
+}
+
+// findRank returns the rank selected by the query
+func findRank(ctx context.Context, db api.PgxConn, sql string, args ...interface{}) (string, error) {
+	var rank string
+	err := db.QueryRow(ctx, sql, args...).Scan(&rank)
+	if err == pgx.ErrNoRows {
+		return "", core.ErrStringNotFound
+	}
+	return rank, err
+}
+
+// Rebalance gives a group of siblings short ranks again, keeping their order
+func (s *StringRepository) Rebalance(siblings core.Siblings) error {
+	ctx := context.Background()
+
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	err = spreadRanks(ctx, tx, siblings.Thread, siblings.Parent)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// FindCrowded finds the groups of siblings of every user with a rank longer
+// than maxLength
+func (s *StringRepository) FindCrowded(maxLength int) ([]core.Siblings, error) {
+	sql := "select thread, parent from string where deleted_at is null " +
+		"group by thread, parent having max(length(rank)) > $1"
+	rows, err := s.DB.Query(context.Background(), sql, maxLength)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	crowded := []core.Siblings{}
+	for rows.Next() {
+		var siblings core.Siblings
+		err = rows.Scan(&siblings.Thread, &siblings.Parent)
+		if err != nil {
+			return nil, err
+		}
+		crowded = append(crowded, siblings)
+	}
+
+	return crowded, rows.Err()
+}
+
+// relocate finds a string a place at the top level of a thread and moves its
+// minor strings along to the new thread. It returns the rank the string
+// should take to end up at order, or last if order is negative or past the
+// end of the destination. The string's own row is left for the caller to
+// update.
+func relocate(ctx context.Context, db api.PgxConn, user uuid.UUID, cs core.String, threadId uuid.UUID, order int) (string, error) {
+	rank, err := rankAt(ctx, db, threadId, uuid.NullUUID{}, order, cs.Id)
+	if err != nil {
+		return "", err
+	}
+
+	if cs.Thread != threadId {
+		err = moveSubtree(ctx, db, user, cs, threadId)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return rank, nil
+}
+
+// moveSubtree moves the minor strings nested under a string to a new thread,
+// keeping their parents and order
+func moveSubtree(ctx context.Context, db api.PgxConn, user uuid.UUID, cs core.String, threadId uuid.UUID) error {
+	sql := "with recursive subtree as (" + subtree + ") " +
+		"select " + columns + " from string where id in (select id from subtree) and id <> $1 for update"
+	moved, err := queryStrings(ctx, db, sql, cs.Id, cs.Owner)
+	if err != nil {
+		return err
+	}
+
+	sql = "update string set thread = $1, owner = (select owner from thread where id = $1), date_modified = CURRENT_TIMESTAMP " +
+		"where id = $2 RETURNING " + columns
+	for i := range moved {
+		after, err := scanString(db.QueryRow(ctx, sql, threadId, moved[i].Id))
+		if err != nil {
+			return api.PgError(err)
+		}
+		err = insertRevision(ctx, db, user, core.RevisionMove, &moved[i], &after)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// UpdateState moves a string into a new lifecycle state and records the
+// transition. The update only applies if the string is still in state `from`.
+func (s *StringRepository) UpdateState(user uuid.UUID, stringId uuid.UUID, from core.State, to core.State) error {
+	ctx := context.Background()
+
+	tx, err := s.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	before, err := findForUpdate(ctx, tx, user, stringId)
+	if err != nil {
+		return err
+	}
+	if before.State != from {
+		return core.ErrInvalidTransition
+	}
+
+	sql := "update string set state = $1, date_modified = CURRENT_TIMESTAMP where id = $2 RETURNING " + columns
+	after, err := scanString(tx.QueryRow(ctx, sql, to, stringId))
+	if err != nil {
+		return err
+	}
+
+	sql = "insert into string_transition (string, from_state, to_state, owner) VALUES ($1, $2, $3, $4)"
+	_, err = tx.Exec(ctx, sql, stringId, from, to, before.Owner)
+	if err != nil {
+		return err
+	}
+
+	err = insertRevision(ctx, tx, user, core.RevisionState, &before, &after)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// FindTransitions returns the lifecycle transitions of a string, oldest first
+func (s *StringRepository) FindTransitions(user uuid.UUID, stringId uuid.UUID) ([]core.StateTransition, error) {
+	sql := "select id, string, from_state, to_state, date_created " +
+		"from string_transition where string = $1 and (owner = $2 or exists(select 1 from string s " +
+		"where s.id = string_transition.string and " + api.Visible("s.thread", "s.owner", "$2") + ")) " +
+		"order by date_created asc"
+	return s.findTransitions(sql, stringId, user)
+}
+
+// FindTransitionsTo returns every transition into the given state of the
+// strings a user can see, oldest first
+func (s *StringRepository) FindTransitionsTo(user uuid.UUID, state core.State) ([]core.StateTransition, error) {
+	sql := "select id, string, from_state, to_state, date_created " +
+		"from string_transition where to_state = $1 and (owner = $2 or exists(select 1 from string s " +
+		"where s.id = string_transition.string and " + api.Visible("s.thread", "s.owner", "$2") + ")) " +
+		"order by date_created asc"
+	return s.findTransitions(sql, state, user)
+}
+
+func (s *StringRepository) findTransitions(sql string, args ...interface{}) ([]core.StateTransition, error) {
+	rows, err := s.DB.Query(context.Background(), sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	transitions := []core.StateTransition{}
+	for rows.Next() {
+		var t core.StateTransition
+		err := rows.Scan(&t.Id, &t.String, &t.From, &t.To, &t.DateCreated)
+		if err != nil {
+			return nil, err
+		}
+		transitions = append(transitions, t)
+	}
+
+	return transitions, rows.Err()
+}
+
+// FindAllAt reconstructs every string a user can see that existed at the
+// given instant from the latest revision of each string recorded at or before
+// it. Strings are visible if the thread they were in at the time is.
+func (s *StringRepository) FindAllAt(user uuid.UUID, at time.Time) ([]core.String, error) {
+	sql := "select after from (" +
+		"select distinct on (string) owner, after from string_revision " +
+		"where date_created <= $1 order by string, date_created desc, seq desc" +
+		") latest where after is not null and " + api.Visible("(latest.after->>'thread')::uuid", "latest.owner", "$2")
+	rows, err := s.DB.Query(context.Background(), sql, at, user)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	strings := []core.String{}
+	for rows.Next() {
+		var cs core.String
+		if err := rows.Scan(&cs); err != nil {
+			return nil, err
+		}
+		strings = append(strings, cs)
+	}
+
+	return strings, rows.Err()
+}
+
+// FindHistory returns every revision recorded for a string, oldest first
+func (s *StringRepository) FindHistory(user uuid.UUID, stringId uuid.UUID) ([]core.StringRevision, error) {
+	sql := "select id, string, action, before, after, actor, date_created " +
+		"from string_revision where string = $1 and (owner = $2 or exists(select 1 from string s " +
+		"where s.id = string_revision.string and " + api.Visible("s.thread", "s.owner", "$2") + ")) " +
+		"order by date_created asc, seq asc"
+	return s.findRevisions(sql, stringId, user)
+}
+
+// FindHistoryByThread returns every revision of every string that has been
+// part of a thread at some point, oldest first
+func (s *StringRepository) FindHistoryByThread(user uuid.UUID, threadId uuid.UUID) ([]core.StringRevision, error) {
+	sql := "select id, string, action, before, after, actor, date_created " +
+		"from string_revision where (after->>'thread' = $1 or before->>'thread' = $1) and (owner = $2 or exists(" +
+		"select 1 from thread t where t.id::text = $1 and " + api.Visible("t.id", "t.owner", "$2") + ")) " +
+		"order by date_created asc, seq asc"
+	return s.findRevisions(sql, threadId.String(), user)
+}
+
+func (s *StringRepository) findRevisions(sql string, args ...interface{}) ([]core.StringRevision, error) {
+	rows, err := s.DB.Query(context.Background(), sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []core.StringRevision{}
+	for rows.Next() {
+		var r core.StringRevision
+		err := rows.Scan(&r.Id, &r.String, &r.Action, &r.Before, &r.After, &r.Actor, &r.DateCreated)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, r)
+	}
+
+	return revisions, rows.Err()
+}
+
+// findForUpdate fetches a string a user can see and locks its row until the
+// transaction ends
+func findForUpdate(ctx context.Context, db api.PgxConn, user uuid.UUID, stringId uuid.UUID) (core.String, error) {
+	sql := "select " + columns + " from string where id = $1 and " + api.Visible("string.thread", "string.owner", "$2") +
+		" and deleted_at is null for update"
+	cs, err := scanString(db.QueryRow(ctx, sql, stringId, user))
+	if err == pgx.ErrNoRows {
+		return core.String{}, core.ErrStringNotFound
+	}
+	return cs, err
+}
+
+// queryStrings runs a query selecting `columns` and collects every row
+func queryStrings(ctx context.Context, db api.PgxConn, sql string, args ...interface{}) ([]core.String, error) {
+	rows, err := db.Query(ctx, sql, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	strings := []core.String{}
+	for rows.Next() {
+		cs, err := scanString(rows)
+		if err != nil {
+			return nil, err
+		}
+		strings = append(strings, cs)
+	}
+
+	return strings, rows.Err()
+}
+
+// rankAt returns a rank that puts a string at order among the given
+// siblings, not counting the string with the id except. A negative order or
+// one past the end puts it last. If the ranks around order leave no room the
+// siblings are spread out first.
+func rankAt(ctx context.Context, db api.PgxConn, threadId uuid.UUID, parent uuid.NullUUID, order int, except uuid.UUID) (string, error) {
+	ranks, err := siblingRanks(ctx, db, threadId, parent, except)
+	if err != nil {
+		return "", err
+	}
+
+	rank, err := rankBefore(ranks, order)
+	if err != core.ErrRankOrder {
+		return rank, err
+	}
+
+	err = spreadRanks(ctx, db, threadId, parent)
+	if err != nil {
+		return "", err
+	}
+	ranks, err = siblingRanks(ctx, db, threadId, parent, except)
+	if err != nil {
+		return "", err
+	}
+	return rankBefore(ranks, order)
+}
+
+// rankBefore returns a rank between ranks[order-1] and ranks[order]
+func rankBefore(ranks []string, order int) (string, error) {
+	if order < 0 || order > len(ranks) {
+		order = len(ranks)
+	}
+
+	var before, after string
+	if order > 0 {
+		before = ranks[order-1]
+	}
+	if order < len(ranks) {
+		after = ranks[order]
+	}
+	return core.RankBetween(before, after)
+}
+
+// siblingRanks returns the ranks of a group of siblings in order
+func siblingRanks(ctx context.Context, db api.PgxConn, threadId uuid.UUID, parent uuid.NullUUID, except uuid.UUID) ([]string, error) {
+	sql := "select rank from string where thread = $1 and parent is not distinct from $2 and id <> $3 " +
+		"and deleted_at is null order by rank, id"
+	rows, err := db.Query(ctx, sql, threadId, parent, except)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	ranks := []string{}
+	for rows.Next() {
+		var rank string
+		if err := rows.Scan(&rank); err != nil {
+			return nil, err
+		}
+		ranks = append(ranks, rank)
+	}
+
+	return ranks, rows.Err()
+}
+
+// spreadRanks gives a group of siblings short, evenly spaced ranks in the
+// order they are already in. No revisions are recorded since no order
+// changes.
+func spreadRanks(ctx context.Context, db api.PgxConn, threadId uuid.UUID, parent uuid.NullUUID) error {
+	sql := "select id from string where thread = $1 and parent is not distinct from $2 " +
+		"and deleted_at is null order by rank, id for update"
+	rows, err := db.Query(ctx, sql, threadId, parent)
+	if err != nil {
+		return err
+	}
+	ids := []uuid.UUID{}
+	for rows.Next() {
+		var id uuid.UUID
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	ranks := core.SpreadRanks(len(ids))
+	for i, id := range ids {
+		_, err = db.Exec(ctx, "update string set rank = $1 where id = $2", ranks[i], id)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// siblingOrders are groups of siblings as they were before a change
+type siblingOrders struct {
+	groups  []core.Siblings
+	strings []core.String
+}
+
+// lockSiblings locks the groups of siblings a change is about to reorder and
+// keeps their orders, so that recordShifts can tell whose order it changed
+func lockSiblings(ctx context.Context, db api.PgxConn, groups ...core.Siblings) (siblingOrders, error) {
+	var orders siblingOrders
+	sql := "select " + columns + " from string where thread = $1 and parent is not distinct from $2 " +
+		"and deleted_at is null order by rank, id for update"
+	for _, group := range groups {
+		if orders.has(group) {
+			continue
+		}
+		strings, err := queryStrings(ctx, db, sql, group.Thread, group.Parent)
+		if err != nil {
+			return siblingOrders{}, err
+		}
+		orders.groups = append(orders.groups, group)
+		orders.strings = append(orders.strings, strings...)
+	}
+	return orders, nil
+}
+
+func (o siblingOrders) has(group core.Siblings) bool {
+	for _, g := range o.groups {
+		if g == group {
+			return true
+		}
+	}
+	return false
+}
+
+// recordShifts records a reorder revision for every sibling still in its
+// group whose order changed, other than the string with the id except, which
+// the change records a revision for itself. Orders are counted from ranks,
+// so moving one string shifts the siblings after its old and new place.
+func (o siblingOrders) recordShifts(ctx context.Context, db api.PgxConn, user uuid.UUID, except uuid.UUID) error {
+	current := make(map[uuid.UUID]core.String, len(o.strings))
+	sql := "select " + columns + " from string where thread = $1 and parent is not distinct from $2 and deleted_at is null"
+	for _, group := range o.groups {
+		strings, err := queryStrings(ctx, db, sql, group.Thread, group.Parent)
+		if err != nil {
+			return err
+		}
+		for _, cs := range strings {
+			current[cs.Id] = cs
+		}
+	}
+
+	sql = "update string set date_modified = CURRENT_TIMESTAMP where id = $1 RETURNING " + columns
+	for i, before := range o.strings {
+		now, ok := current[before.Id]
+		if before.Id == except || !ok || now.Order == before.Order {
+			continue
+		}
+
+		after, err := scanString(db.QueryRow(ctx, sql, before.Id))
+		if err != nil {
+			return err
+		}
+		err = insertRevision(ctx, db, user, core.RevisionReorder, &o.strings[i], &after)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deleteWithRevisions runs an `update ... set deleted_at ... RETURNING columns`
+// statement and records a delete revision for every string it moved to the
+// trash
+func deleteWithRevisions(ctx context.Context, db api.PgxConn, user uuid.UUID, sql string, args ...interface{}) error {
+	deleted, err := queryStrings(ctx, db, sql, args...)
+	if err != nil {
+		return err
+	}
+
+	for i := range deleted {
+		deleted[i].DeletedAt = nil
+		err = insertRevision(ctx, db, user, core.RevisionDelete, &deleted[i], nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// insertRevision records a change made by actor. The revision belongs to the
+// owner of the string.
+func insertRevision(ctx context.Context, db api.PgxConn, actor uuid.UUID, action core.RevisionAction, before, after *core.String) error {
+	subject := after
+	if subject == nil {
+		subject = before
+	}
+	sql := "insert into string_revision (string, action, before, after, actor, owner) VALUES ($1, $2, $3, $4, $5, $6)"
+	_, err := db.Exec(ctx, sql, subject.Id, action, before, after, actor, subject.Owner)
 	return err
 }
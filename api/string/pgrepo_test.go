@@ -0,0 +1,218 @@
+package string
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gofrs/uuid"
+	"github.com/orpheus/strings/api/thread"
+	"github.com/orpheus/strings/api/user"
+	"github.com/orpheus/strings/core"
+	"github.com/orpheus/strings/infrastructure/logging"
+	"github.com/orpheus/strings/infrastructure/postgres/pgtest"
+	"testing"
+)
+
+// fixture is a user of a test database with the threads made by newThread
+type fixture struct {
+	repo    *StringRepository
+	threads *thread.Repository
+	user    core.User
+}
+
+func newFixture(tb testing.TB) fixture {
+	conn := pgtest.Connect(tb)
+	logger := &logging.TmpLogger{}
+	f := fixture{
+		repo:    &StringRepository{DB: conn, Logger: logger},
+		threads: &thread.Repository{DB: conn, Logger: logger},
+	}
+
+	var err error
+	f.user, err = (&user.Repository{DB: conn, Logger: logger}).CreateOne(core.User{Username: pgtest.Unique("owner")})
+	if err != nil {
+		tb.Fatalf("failed to create a user: %s", err)
+	}
+	return f
+}
+
+// newThread creates a thread with n top level strings and returns them in
+// order
+func (f fixture) newThread(tb testing.TB, n int) (core.Thread, []core.String) {
+	t, err := f.threads.CreateOne(f.user.Id, core.Thread{Name: pgtest.Unique("thread"), Kind: core.KindActionable})
+	if err != nil {
+		tb.Fatalf("failed to create a thread: %s", err)
+	}
+
+	strings := make([]core.String, n)
+	for i := range strings {
+		strings[i], err = f.repo.CreateOne(f.user.Id, core.String{
+			Name:   fmt.Sprintf("String %d", i),
+			Thread: t.Id,
+			Kind:   core.KindActionable,
+			State:  core.KindActionable.InitialState(),
+		})
+		if err != nil {
+			tb.Fatalf("failed to create a string: %s", err)
+		}
+	}
+	return t, strings
+}
+
+// assertShifted fails the test unless the latest revision of a string is a
+// reorder to the given order
+func (f fixture) assertShifted(t *testing.T, cs core.String, order int) {
+	t.Helper()
+	history, err := f.repo.FindHistory(f.user.Id, cs.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	latest := history[len(history)-1]
+	if latest.Action != core.RevisionReorder || latest.After.Order != order {
+		t.Errorf("%q was last revised by %s to order %d, want reorder to %d", cs.Name, latest.Action, latest.After.Order, order)
+	}
+}
+
+// assertUnshifted fails the test if a string has any revision besides its
+// creation
+func (f fixture) assertUnshifted(t *testing.T, cs core.String) {
+	t.Helper()
+	history, err := f.repo.FindHistory(f.user.Id, cs.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(history) != 1 {
+		t.Errorf("%q has %d revisions, want only its creation", cs.Name, len(history))
+	}
+}
+
+func TestRepositionRecordsTheSiblingsItShifts(t *testing.T) {
+	f := newFixture(t)
+	_, strings := f.newThread(t, 5)
+
+	// 0 1 2 3 4 becomes 0 4 1 2 3
+	err := f.repo.Reposition(f.user.Id, strings[4].Id, uuid.NullUUID{UUID: strings[0].Id, Valid: true}, uuid.NullUUID{})
+	if err != nil {
+		t.Fatalf("Reposition failed: %s", err)
+	}
+
+	f.assertUnshifted(t, strings[0])
+	f.assertShifted(t, strings[4], 1)
+	for i := 1; i < 4; i++ {
+		f.assertShifted(t, strings[i], i+1)
+	}
+}
+
+func TestMoveRecordsTheSiblingsOfBothThreads(t *testing.T) {
+	f := newFixture(t)
+	_, from := f.newThread(t, 3)
+	to, into := f.newThread(t, 2)
+
+	err := f.repo.Move(f.user.Id, from[0].Id, to.Id, 1)
+	if err != nil {
+		t.Fatalf("Move failed: %s", err)
+	}
+
+	f.assertShifted(t, from[1], 0)
+	f.assertShifted(t, from[2], 1)
+	f.assertUnshifted(t, into[0])
+	f.assertShifted(t, into[1], 2)
+}
+
+func TestDeleteByIdRecordsTheSiblingsAfterIt(t *testing.T) {
+	f := newFixture(t)
+	_, strings := f.newThread(t, 3)
+
+	err := f.repo.DeleteById(f.user.Id, strings[1].Id)
+	if err != nil {
+		t.Fatalf("DeleteById failed: %s", err)
+	}
+
+	f.assertUnshifted(t, strings[0])
+	f.assertShifted(t, strings[2], 1)
+}
+
+func TestUpdateRecordsTheSiblingsItShifts(t *testing.T) {
+	f := newFixture(t)
+	_, strings := f.newThread(t, 3)
+
+	order := 0
+	_, err := f.repo.Update(f.user.Id, strings[2].Id, core.StringPatch{Order: &order})
+	if err != nil {
+		t.Fatalf("Update failed: %s", err)
+	}
+
+	f.assertShifted(t, strings[0], 1)
+	f.assertShifted(t, strings[1], 2)
+}
+
+// BenchmarkReposition moves the last of n siblings to the front and back
+func BenchmarkReposition(b *testing.B) {
+	for _, n := range []int{10, 100, 1000} {
+		b.Run(fmt.Sprintf("%d siblings", n), func(b *testing.B) {
+			f := newFixture(b)
+			_, strings := f.newThread(b, n)
+			first := uuid.NullUUID{UUID: strings[0].Id, Valid: true}
+			last := uuid.NullUUID{UUID: strings[n-1].Id, Valid: true}
+			moving := strings[n/2].Id
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				after, before := uuid.NullUUID{}, first
+				if i%2 == 1 {
+					after, before = last, uuid.NullUUID{}
+				}
+				err := f.repo.Reposition(f.user.Id, moving, after, before)
+				if err != nil {
+					b.Fatalf("Reposition failed: %s", err)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkUpdateOrder reverses the order of n siblings
+func BenchmarkUpdateOrder(b *testing.B) {
+	for _, n := range []int{10, 100, 1000} {
+		b.Run(fmt.Sprintf("%d siblings", n), func(b *testing.B) {
+			f := newFixture(b)
+			_, strings := f.newThread(b, n)
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				orders := make([]core.StringOrder, n)
+				for j, cs := range strings {
+					order := n - 1 - j
+					if i%2 == 1 {
+						order = j
+					}
+					orders[j] = core.StringOrder{Id: cs.Id, Order: order}
+				}
+				err := f.repo.UpdateOrder(f.user.Id, orders)
+				if err != nil {
+					b.Fatalf("UpdateOrder failed: %s", err)
+				}
+			}
+		})
+	}
+}
+
+func TestMoveRefusesThreadsWithTheNameOfAMinorString(t *testing.T) {
+	f := newFixture(t)
+	_, from := f.newThread(t, 1)
+	to, taken := f.newThread(t, 1)
+	_, err := f.repo.CreateOne(f.user.Id, core.String{
+		Name:   taken[0].Name,
+		Thread: from[0].Thread,
+		Parent: uuid.NullUUID{UUID: from[0].Id, Valid: true},
+		Kind:   core.KindActionable,
+		State:  core.KindActionable.InitialState(),
+	})
+	if err != nil {
+		t.Fatalf("failed to create a minor string: %s", err)
+	}
+
+	err = f.repo.Move(f.user.Id, from[0].Id, to.Id, 0)
+	if !errors.Is(err, core.ErrStringNameTaken) {
+		t.Errorf("Move returned %v, want %v", err, core.ErrStringNameTaken)
+	}
+}
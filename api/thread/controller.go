@@ -4,9 +4,9 @@ import (
 	"fmt"
 	"github.com/gin-gonic/gin"
 	"github.com/gofrs/uuid"
+	"github.com/orpheus/strings/api"
 	"github.com/orpheus/strings/core"
 	"github.com/orpheus/strings/infrastructure/logging"
-	"log"
 	"net/http"
 )
 
@@ -16,24 +16,38 @@ type Controller struct {
 }
 
 type Interactor interface {
-	FindAll() ([]core.Thread, error)
-	CreateOne(thread core.Thread) (core.Thread, error)
-	DeleteById(id uuid.UUID) error
+	FindAll(user uuid.UUID, archived bool) ([]core.Thread, error)
+	FindById(user uuid.UUID, id uuid.UUID) (core.Thread, error)
+	CreateOne(user uuid.UUID, thread core.Thread) (core.Thread, error)
+	Update(user uuid.UUID, thread core.Thread) (core.Thread, error)
+	UpdateOrder(user uuid.UUID, threadOrders []core.ThreadOrder) error
+	SetPinned(user uuid.UUID, id uuid.UUID, pinned bool) (core.Thread, error)
+	SetArchived(user uuid.UUID, id uuid.UUID, archived bool) (core.Thread, error)
+	DeleteById(user uuid.UUID, id uuid.UUID) error
 }
 
 func (s *Controller) RegisterRoutes(router *gin.RouterGroup) {
 	thread := router.Group("/thread")
 	{
 		thread.GET("", s.FindAll)
+		thread.GET("/:id", s.FindById)
 		thread.POST("", s.CreateOne)
+		thread.PUT("/:id", s.Update)
+		thread.PUT("/updateOrder", s.UpdateOrder)
+		thread.PUT("/:id/pin", s.Pin)
+		thread.DELETE("/:id/pin", s.Unpin)
+		thread.PUT("/:id/archive", s.Archive)
+		thread.DELETE("/:id/archive", s.Unarchive)
 		thread.DELETE("/:id", s.DeleteById)
 	}
 }
 
+// FindAll fetches the active threads, pinned first and then in order. Pass
+// `archived=true` as a query param to fetch the archived threads instead.
 func (s *Controller) FindAll(c *gin.Context) {
-	threads, err := s.Interactor.FindAll()
+	threads, err := s.Interactor.FindAll(api.CurrentUserId(c), c.Query("archived") == "true")
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, err.Error())
+		api.Fail(c, err)
 		return
 	}
 	c.IndentedJSON(http.StatusOK, threads)
@@ -42,29 +56,141 @@ func (s *Controller) FindAll(c *gin.Context) {
 func (s *Controller) CreateOne(c *gin.Context) {
 	var thread core.Thread
 	if err := c.ShouldBindJSON(&thread); err != nil {
-		c.JSON(http.StatusBadRequest, err.Error())
+		api.Fail(c, core.Validation(err.Error()))
 		return
 	}
-	newThread, err := s.Interactor.CreateOne(thread)
+	newThread, err := s.Interactor.CreateOne(api.CurrentUserId(c), thread)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": fmt.Sprintf("%s", err.Error())},
-		)
+		api.Fail(c, err)
 		return
 	}
 	c.IndentedJSON(http.StatusOK, newThread)
 }
 
+// FindById fetches a single thread with its strings embedded in order
+func (s *Controller) FindById(c *gin.Context) {
+	threadId, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		api.Fail(c, core.Validation(fmt.Sprintf("Failed to parse thread id: %s", err.Error())))
+		return
+	}
+
+	thread, err := s.Interactor.FindById(api.CurrentUserId(c), threadId)
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, thread)
+}
+
+// Update replaces the name and description of a thread
+func (s *Controller) Update(c *gin.Context) {
+	threadId, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		api.Fail(c, core.Validation(fmt.Sprintf("Failed to parse thread id: %s", err.Error())))
+		return
+	}
+
+	var thread core.Thread
+	if err := c.ShouldBindJSON(&thread); err != nil {
+		api.Fail(c, core.Validation(err.Error()))
+		return
+	}
+	thread.Id = threadId
+
+	updated, err := s.Interactor.Update(api.CurrentUserId(c), thread)
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, updated)
+}
+
+// ThreadOrderDTO binds the request body of UpdateOrder, see string.StringOrderDTO
+type ThreadOrderDTO struct {
+	Id    string `json:"id" binding:"required"`
+	Order int    `json:"order"`
+}
+
+// UpdateOrder takes a list of id and order values and updates the threads
+func (s *Controller) UpdateOrder(c *gin.Context) {
+	var threadOrderDTOs []ThreadOrderDTO
+	if err := c.ShouldBindJSON(&threadOrderDTOs); err != nil {
+		api.Fail(c, core.Validation(fmt.Sprintf("Failed to bind `threadOrders`: %s", err.Error())))
+		return
+	}
+
+	var threadOrders []core.ThreadOrder
+	for _, dto := range threadOrderDTOs {
+		id, err := uuid.FromString(dto.Id)
+		if err != nil {
+			api.Fail(c, core.Validation(fmt.Sprintf("Failed to parse thread id: %s", err.Error())))
+			return
+		}
+		threadOrders = append(threadOrders, core.ThreadOrder{
+			Id:    id,
+			Order: dto.Order,
+		})
+	}
+
+	err := s.Interactor.UpdateOrder(api.CurrentUserId(c), threadOrders)
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, true)
+}
+
+// Pin keeps a thread at the top of the list
+func (s *Controller) Pin(c *gin.Context) {
+	s.setFlag(c, s.Interactor.SetPinned, true)
+}
+
+// Unpin returns a pinned thread to its place in the list
+func (s *Controller) Unpin(c *gin.Context) {
+	s.setFlag(c, s.Interactor.SetPinned, false)
+}
+
+// Archive puts a thread away without deleting it
+func (s *Controller) Archive(c *gin.Context) {
+	s.setFlag(c, s.Interactor.SetArchived, true)
+}
+
+// Unarchive brings an archived thread back into the list
+func (s *Controller) Unarchive(c *gin.Context) {
+	s.setFlag(c, s.Interactor.SetArchived, false)
+}
+
+func (s *Controller) setFlag(c *gin.Context, set func(user uuid.UUID, id uuid.UUID, value bool) (core.Thread, error), value bool) {
+	threadId, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		api.Fail(c, core.Validation(fmt.Sprintf("Failed to parse thread id: %s", err.Error())))
+		return
+	}
+
+	thread, err := set(api.CurrentUserId(c), threadId, value)
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+
+	c.IndentedJSON(http.StatusOK, thread)
+}
+
 func (s *Controller) DeleteById(c *gin.Context) {
 	threadId, err := uuid.FromString(c.Param("id"))
 	if err != nil {
-		log.Fatalf("failed to parse UUID %q: %v", s, err)
+		api.Fail(c, core.Validation(fmt.Sprintf("Failed to parse thread id: %s", err.Error())))
+		return
 	}
 
-	err = s.Interactor.DeleteById(threadId)
+	err = s.Interactor.DeleteById(api.CurrentUserId(c), threadId)
 
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, err.Error())
+		api.Fail(c, err)
 		return
 	}
 
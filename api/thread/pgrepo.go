@@ -2,12 +2,12 @@ package thread
 
 import (
 	"context"
-	"fmt"
 	"github.com/gofrs/uuid"
+	"github.com/jackc/pgx/v4"
 	"github.com/orpheus/strings/api"
 	"github.com/orpheus/strings/core"
 	"github.com/orpheus/strings/infrastructure/logging"
-	"log"
+	"time"
 )
 
 type Repository struct {
@@ -15,8 +15,23 @@ type Repository struct {
 	Logger logging.Logger
 }
 
-func (r *Repository) FindAll() ([]core.Thread, error) {
-	threadRows, err := r.DB.Query(context.Background(), "select * from thread")
+// columns lists the thread columns in the order scanThread expects them
+const columns = "id, name, description, kind, \"order\", pinned, archived, owner, date_created, date_modified, deleted_at"
+
+// scanThread scans a single row selected with `columns` into a core.Thread
+func scanThread(row pgx.Row) (core.Thread, error) {
+	var t core.Thread
+	err := row.Scan(&t.Id, &t.Name, &t.Description, &t.Kind, &t.Order, &t.Pinned, &t.Archived, &t.Owner, &t.DateCreated, &t.DateModified, &t.DeletedAt)
+	return t, err
+}
+
+// FindAll fetches either the active or the archived threads a user owns or
+// that were shared with them, pinned threads first and then in order
+func (r *Repository) FindAll(user uuid.UUID, archived bool) ([]core.Thread, error) {
+	sql := "select " + columns + " from thread where archived = $1 and " + api.Visible("thread.id", "thread.owner", "$2") +
+		" and deleted_at is null " +
+		"order by pinned desc, \"order\" asc, date_created asc"
+	threadRows, err := r.DB.Query(context.Background(), sql, archived, user)
 	if err != nil {
 		return nil, err
 	}
@@ -24,12 +39,11 @@ func (r *Repository) FindAll() ([]core.Thread, error) {
 
 	var threads []core.Thread
 	for threadRows.Next() {
-		r := new(core.Thread)
-		err := threadRows.Scan(&r.Id, &r.Name, &r.Description, &r.DateCreated, &r.DateModified)
+		r, err := scanThread(threadRows)
 		if err != nil {
-			log.Fatal(err)
+			return nil, err
 		}
-		threads = append(threads, *r)
+		threads = append(threads, r)
 	}
 
 	if err := threadRows.Err(); err != nil {
@@ -43,20 +57,323 @@ func (r *Repository) FindAll() ([]core.Thread, error) {
 	return threads, nil
 }
 
-func (r *Repository) CreateOne(thread core.Thread) (core.Thread, error) {
-	sql := "insert into thread (name, description) " +
-		"VALUES ($1, $2) " +
-		"RETURNING id, name, description"
-	t := new(core.Thread)
-	err := r.DB.QueryRow(context.Background(), sql, thread.Name, thread.Description).
-		Scan(&t.Id, &t.Name, &t.Description)
+func (r *Repository) FindById(user uuid.UUID, id uuid.UUID) (core.Thread, error) {
+	sql := "select " + columns + " from thread where id = $1 and " + api.Visible("thread.id", "thread.owner", "$2") + " and deleted_at is null"
+	t, err := scanThread(r.DB.QueryRow(context.Background(), sql, id, user))
+	if err == pgx.ErrNoRows {
+		return core.Thread{}, core.ErrThreadNotFound
+	}
+	return t, err
+}
+
+// FindAllAt reconstructs every thread a user can see that existed at the
+// given instant from the latest revision of each thread recorded at or before
+// it
+func (r *Repository) FindAllAt(user uuid.UUID, at time.Time) ([]core.Thread, error) {
+	sql := "select after from (" +
+		"select distinct on (thread) thread, owner, after from thread_revision " +
+		"where date_created <= $1 order by thread, date_created desc, seq desc" +
+		") latest where after is not null and " + api.Visible("latest.thread", "latest.owner", "$2")
+	rows, err := r.DB.Query(context.Background(), sql, at, user)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	threads := []core.Thread{}
+	for rows.Next() {
+		var t core.Thread
+		if err := rows.Scan(&t); err != nil {
+			return nil, err
+		}
+		threads = append(threads, t)
+	}
+
+	return threads, rows.Err()
+}
+
+// CreateOne saves a new thread owned by user
+func (r *Repository) CreateOne(user uuid.UUID, thread core.Thread) (core.Thread, error) {
+	ctx := context.Background()
+
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return core.Thread{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	// new threads go last
+	sql := "insert into thread (name, description, kind, owner, \"order\") " +
+		"VALUES ($1, $2, $3, $4, (select coalesce(max(\"order\") + 1, 0) from thread where owner = $4 and deleted_at is null)) " +
+		"RETURNING " + columns
+	t, err := scanThread(tx.QueryRow(ctx, sql, thread.Name, thread.Description, thread.Kind, user))
+	if err != nil {
+		return core.Thread{}, api.PgError(err)
+	}
+
+	err = insertRevision(ctx, tx, user, core.RevisionCreate, nil, &t)
+	if err != nil {
+		return core.Thread{}, err
+	}
+
+	return t, tx.Commit(ctx)
+}
+
+// Update saves the name and description of a thread
+func (r *Repository) Update(user uuid.UUID, thread core.Thread) (core.Thread, error) {
+	ctx := context.Background()
+
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return core.Thread{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	before, err := findForUpdate(ctx, tx, user, thread.Id)
+	if err != nil {
+		return core.Thread{}, err
+	}
+
+	if before.Name == thread.Name && before.Description == thread.Description {
+		return before, nil
+	}
+
+	sql := "update thread set name = $1, description = $2, date_modified = CURRENT_TIMESTAMP " +
+		"where id = $3 RETURNING " + columns
+	after, err := scanThread(tx.QueryRow(ctx, sql, thread.Name, thread.Description, thread.Id))
+	if err != nil {
+		return core.Thread{}, api.PgError(err)
+	}
+
+	err = insertRevision(ctx, tx, user, core.RevisionUpdate, &before, &after)
+	if err != nil {
+		return core.Thread{}, err
+	}
+
+	return after, tx.Commit(ctx)
+}
+
+// UpdateOrder saves the order of every given thread in one transaction
+func (r *Repository) UpdateOrder(user uuid.UUID, threadOrders []core.ThreadOrder) error {
+	ctx := context.Background()
+
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	sql := "update thread set \"order\" = $1, date_modified = CURRENT_TIMESTAMP where id = $2 RETURNING " + columns
+
+	for _, threadOrder := range threadOrders {
+		before, err := findForUpdate(ctx, tx, user, threadOrder.Id)
+		if err == core.ErrThreadNotFound {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if before.Order == threadOrder.Order {
+			continue
+		}
+
+		after, err := scanThread(tx.QueryRow(ctx, sql, threadOrder.Order, threadOrder.Id))
+		if err != nil {
+			return err
+		}
+
+		err = insertRevision(ctx, tx, user, core.RevisionReorder, &before, &after)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
+
+// SetPinned pins a thread to the top of the list or unpins it
+func (r *Repository) SetPinned(user uuid.UUID, id uuid.UUID, pinned bool) (core.Thread, error) {
+	return r.setFlag(user, id, "pinned", pinned)
+}
 
-	fmt.Println(r, err)
-	return *t, err
+// SetArchived puts a thread away or brings it back
+func (r *Repository) SetArchived(user uuid.UUID, id uuid.UUID, archived bool) (core.Thread, error) {
+	return r.setFlag(user, id, "archived", archived)
 }
 
-func (r *Repository) DeleteById(id uuid.UUID) error {
-	sql := "delete from thread where id = $1"
-	_, err := r.DB.Exec(context.Background(), sql, id)
+// setFlag sets one of the boolean columns of a thread. column is never user
+// input.
+func (r *Repository) setFlag(user uuid.UUID, id uuid.UUID, column string, value bool) (core.Thread, error) {
+	ctx := context.Background()
+
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return core.Thread{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	before, err := findForUpdate(ctx, tx, user, id)
+	if err != nil {
+		return core.Thread{}, err
+	}
+
+	sql := "update thread set " + column + " = $1, date_modified = CURRENT_TIMESTAMP where id = $2 RETURNING " + columns
+	after, err := scanThread(tx.QueryRow(ctx, sql, value, id))
+	if err != nil {
+		return core.Thread{}, err
+	}
+
+	if before.Pinned != after.Pinned || before.Archived != after.Archived {
+		err = insertRevision(ctx, tx, user, core.RevisionUpdate, &before, &after)
+		if err != nil {
+			return core.Thread{}, err
+		}
+	}
+
+	return after, tx.Commit(ctx)
+}
+
+// DeleteById moves a thread to the trash
+func (r *Repository) DeleteById(user uuid.UUID, id uuid.UUID, deletedAt time.Time) error {
+	ctx := context.Background()
+
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	sql := "update thread set deleted_at = $2 where id = $1 and owner = $3 and deleted_at is null RETURNING " + columns
+	t, err := scanThread(tx.QueryRow(ctx, sql, id, deletedAt, user))
+	if err == pgx.ErrNoRows {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	t.DeletedAt = nil
+	err = insertRevision(ctx, tx, user, core.RevisionDelete, &t, nil)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// FindRole returns the role a user has on a thread, or ErrThreadNotFound if
+// they can not see it
+func (r *Repository) FindRole(user uuid.UUID, id uuid.UUID) (core.Role, error) {
+	sql := "select case when owner = $2 then 'owner' " +
+		"else (select role from thread_member where thread = $1 and member = $2) end " +
+		"from thread where id = $1 and deleted_at is null"
+	var role *core.Role
+	err := r.DB.QueryRow(context.Background(), sql, id, user).Scan(&role)
+	if err == pgx.ErrNoRows || (err == nil && role == nil) {
+		return "", core.ErrThreadNotFound
+	}
+	if err != nil {
+		return "", err
+	}
+	return *role, nil
+}
+
+// FindDeleted returns every thread of a user in the trash, most recently
+// deleted first
+func (r *Repository) FindDeleted(user uuid.UUID) ([]core.Thread, error) {
+	sql := "select " + columns + " from thread where owner = $1 and deleted_at is not null order by deleted_at desc"
+	rows, err := r.DB.Query(context.Background(), sql, user)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	threads := []core.Thread{}
+	for rows.Next() {
+		t, err := scanThread(rows)
+		if err != nil {
+			return nil, err
+		}
+		threads = append(threads, t)
+	}
+
+	return threads, rows.Err()
+}
+
+// FindDeletedById fetches a thread that is in the trash
+func (r *Repository) FindDeletedById(user uuid.UUID, id uuid.UUID) (core.Thread, error) {
+	sql := "select " + columns + " from thread where id = $1 and owner = $2 and deleted_at is not null"
+	t, err := scanThread(r.DB.QueryRow(context.Background(), sql, id, user))
+	if err == pgx.ErrNoRows {
+		return core.Thread{}, core.ErrThreadNotFound
+	}
+	return t, err
+}
+
+// RestoreById takes a thread out of the trash
+func (r *Repository) RestoreById(user uuid.UUID, id uuid.UUID) (core.Thread, error) {
+	ctx := context.Background()
+
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return core.Thread{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	sql := "select " + columns + " from thread where id = $1 and owner = $2 and deleted_at is not null for update"
+	before, err := scanThread(tx.QueryRow(ctx, sql, id, user))
+	if err == pgx.ErrNoRows {
+		return core.Thread{}, core.ErrThreadNotFound
+	}
+	if err != nil {
+		return core.Thread{}, err
+	}
+
+	sql = "update thread set deleted_at = null, date_modified = CURRENT_TIMESTAMP where id = $1 RETURNING " + columns
+	after, err := scanThread(tx.QueryRow(ctx, sql, id))
+	if err != nil {
+		return core.Thread{}, api.PgError(err)
+	}
+
+	err = insertRevision(ctx, tx, user, core.RevisionRestore, &before, &after)
+	if err != nil {
+		return core.Thread{}, err
+	}
+
+	return after, tx.Commit(ctx)
+}
+
+// Purge permanently deletes the threads of every user that went to the trash
+// before the given time and no longer have any strings. Revisions are kept.
+func (r *Repository) Purge(before time.Time) (int64, error) {
+	sql := "delete from thread where deleted_at < $1 and not exists(select 1 from string where string.thread = thread.id)"
+	tag, err := r.DB.Exec(context.Background(), sql, before)
+	if err != nil {
+		return 0, err
+	}
+	return tag.RowsAffected(), nil
+}
+
+// findForUpdate fetches a thread a user can see and locks its row until the
+// transaction ends
+func findForUpdate(ctx context.Context, db api.PgxConn, user uuid.UUID, id uuid.UUID) (core.Thread, error) {
+	sql := "select " + columns + " from thread where id = $1 and " + api.Visible("thread.id", "thread.owner", "$2") +
+		" and deleted_at is null for update"
+	t, err := scanThread(db.QueryRow(ctx, sql, id, user))
+	if err == pgx.ErrNoRows {
+		return core.Thread{}, core.ErrThreadNotFound
+	}
+	return t, err
+}
+
+// insertRevision records a change made by actor. The revision belongs to the
+// owner of the thread.
+func insertRevision(ctx context.Context, db api.PgxConn, actor uuid.UUID, action core.RevisionAction, before, after *core.Thread) error {
+	subject := after
+	if subject == nil {
+		subject = before
+	}
+	sql := "insert into thread_revision (thread, action, before, after, actor, owner) VALUES ($1, $2, $3, $4, $5, $6)"
+	_, err := db.Exec(ctx, sql, subject.Id, action, before, after, actor, subject.Owner)
 	return err
 }
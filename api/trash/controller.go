@@ -0,0 +1,62 @@
+package trash
+
+import (
+	"errors"
+	"fmt"
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+	"github.com/orpheus/strings/api"
+	"github.com/orpheus/strings/core"
+	"github.com/orpheus/strings/infrastructure/logging"
+	"net/http"
+)
+
+// Controller serves the threads and strings that have been deleted
+type Controller struct {
+	Interactor Interactor
+	Logger     logging.Logger
+}
+
+type Interactor interface {
+	FindAll(user uuid.UUID) (core.Trash, error)
+	Restore(user uuid.UUID, id uuid.UUID) (core.Trash, error)
+}
+
+func (s *Controller) RegisterRoutes(router *gin.RouterGroup) {
+	trash := router.Group("/trash")
+	{
+		trash.GET("", s.FindAll)
+		trash.POST("/:id/restore", s.Restore)
+	}
+}
+
+// FindAll lists every thread and string in the trash
+func (s *Controller) FindAll(c *gin.Context) {
+	trash, err := s.Interactor.FindAll(api.CurrentUserId(c))
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, trash)
+}
+
+// Restore takes the thread or string with the given id out of the trash and
+// returns what was restored
+func (s *Controller) Restore(c *gin.Context) {
+	id, err := uuid.FromString(c.Param("id"))
+	if err != nil {
+		api.Fail(c, core.Validation(fmt.Sprintf("Failed to parse id: %s", err.Error())))
+		return
+	}
+
+	restored, err := s.Interactor.Restore(api.CurrentUserId(c), id)
+	if errors.Is(err, core.ErrStringNotFound) {
+		err = core.ErrNotInTrash
+	}
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, restored)
+}
@@ -0,0 +1,37 @@
+package user
+
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+	"github.com/orpheus/strings/api"
+	"github.com/orpheus/strings/core"
+	"github.com/orpheus/strings/infrastructure/logging"
+	"net/http"
+)
+
+// Controller serves the account of the signed in user
+type Controller struct {
+	Interactor Interactor
+	Logger     logging.Logger
+}
+
+type Interactor interface {
+	FindById(id uuid.UUID) (core.User, error)
+}
+
+func (s *Controller) RegisterRoutes(router *gin.RouterGroup) {
+	user := router.Group("/user")
+	{
+		user.GET("/me", s.Me)
+	}
+}
+
+// Me returns the account of the user making the request
+func (s *Controller) Me(c *gin.Context) {
+	user, err := s.Interactor.FindById(api.CurrentUserId(c))
+	if err != nil {
+		api.Fail(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, user)
+}
@@ -0,0 +1,110 @@
+package user
+
+import (
+	"context"
+	"github.com/gofrs/uuid"
+	"github.com/jackc/pgx/v4"
+	"github.com/orpheus/strings/api"
+	"github.com/orpheus/strings/core"
+	"github.com/orpheus/strings/infrastructure/logging"
+)
+
+type Repository struct {
+	DB     api.PgxConn
+	Logger logging.Logger
+}
+
+// signUpLock is the key of the advisory lock held while creating a user, so
+// that only one of the first users to sign up at once claims what is unowned
+const signUpLock = 7_271_832_091
+
+// columns lists the user columns in the order scanUser expects them
+const columns = "id, username, date_created, date_modified"
+
+// scanUser scans a single row selected with `columns` into a core.User
+func scanUser(row pgx.Row) (core.User, error) {
+	var u core.User
+	err := row.Scan(&u.Id, &u.Username, &u.DateCreated, &u.DateModified)
+	if err == pgx.ErrNoRows {
+		return core.User{}, core.ErrUserNotFound
+	}
+	return u, err
+}
+
+func (r *Repository) FindById(id uuid.UUID) (core.User, error) {
+	sql := "select " + columns + " from app_user where id = $1"
+	return scanUser(r.DB.QueryRow(context.Background(), sql, id))
+}
+
+// FindByUsername fetches a user by name, ignoring case
+func (r *Repository) FindByUsername(username string) (core.User, error) {
+	sql := "select " + columns + " from app_user where lower(username) = lower($1)"
+	return scanUser(r.DB.QueryRow(context.Background(), sql, username))
+}
+
+func (r *Repository) CreateOne(user core.User) (core.User, error) {
+	sql := "insert into app_user (username) VALUES ($1) RETURNING " + columns
+	created, err := scanUser(r.DB.QueryRow(context.Background(), sql, user.Username))
+	return created, api.PgError(err)
+}
+
+// FindPasswordHash fetches a user by name, ignoring case, along with the hash
+// of their password. Users without a password have an empty hash.
+func (r *Repository) FindPasswordHash(username string) (core.User, string, error) {
+	sql := "select " + columns + ", coalesce(password_hash, '') from app_user where lower(username) = lower($1)"
+	var u core.User
+	var hash string
+	err := r.DB.QueryRow(context.Background(), sql, username).Scan(&u.Id, &u.Username, &u.DateCreated, &u.DateModified, &hash)
+	if err == pgx.ErrNoRows {
+		return core.User{}, "", core.ErrUserNotFound
+	}
+	return u, hash, err
+}
+
+// SetPassword replaces the password hash of a user
+func (r *Repository) SetPassword(id uuid.UUID, hash string) error {
+	sql := "update app_user set password_hash = $1, date_modified = CURRENT_TIMESTAMP where id = $2"
+	tag, err := r.DB.Exec(context.Background(), sql, hash, id)
+	if err != nil {
+		return err
+	}
+	if tag.RowsAffected() == 0 {
+		return core.ErrUserNotFound
+	}
+	return nil
+}
+
+// LockSignUps waits for any other user being created to be committed and
+// keeps new ones waiting until the transaction it runs in ends
+func (r *Repository) LockSignUps() error {
+	_, err := r.DB.Exec(context.Background(), "select pg_advisory_xact_lock($1)", signUpLock)
+	return err
+}
+
+// Count returns the number of users
+func (r *Repository) Count() (int, error) {
+	var count int
+	err := r.DB.QueryRow(context.Background(), "select count(*) from app_user").Scan(&count)
+	return count, err
+}
+
+// ClaimUnowned gives a user every thread, string, revision and transition
+// that was created before users existed
+func (r *Repository) ClaimUnowned(id uuid.UUID) error {
+	ctx := context.Background()
+
+	tx, err := r.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	for _, table := range []string{"thread", "string", "thread_revision", "string_revision", "string_transition"} {
+		_, err = tx.Exec(ctx, "update "+table+" set owner = $1 where owner is null", id)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit(ctx)
+}
@@ -0,0 +1,140 @@
+// Package cli is the command line client of the service. It manages threads
+// and strings through the HTTP api of a running server, using the same core
+// types as the controllers on the wire.
+package cli
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/orpheus/strings/client"
+	"github.com/orpheus/strings/util"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+)
+
+const Usage = `Usage:
+  strings thread ls [--archived]
+  strings thread add <name> [--description <text>] [--kind actionable|active]
+  strings thread rm <thread>
+  strings thread rename <thread> <name>
+  strings string ls --thread <thread>
+  strings string add <name> --thread <thread> [--parent <string>] [--description <text>] [--kind actionable|active]
+  strings string mv <string> --thread <thread> [--order <n>]
+  strings string mv <string> [--after <string>] [--before <string>]
+  strings string reorder --thread <thread> [--parent <string>]
+
+A <thread> is the id or the name of a thread, a <string> is the id of a string.
+Every command takes --json to print what the server answered as JSON instead
+of a table.
+
+The server is at STRINGS_URL (default http://localhost:8080) and requests are
+signed in with the session token or API key in STRINGS_TOKEN.
+`
+
+// ErrUsage is returned when a command is called the wrong way. The usage has
+// been printed already.
+var ErrUsage = errors.New("invalid usage")
+
+// Cli runs commands against the server Client talks to
+type Cli struct {
+	Client *client.Client
+	In     io.Reader
+	Out    io.Writer
+	Err    io.Writer
+}
+
+// New makes a Cli for the server named by STRINGS_URL, signed in with
+// STRINGS_TOKEN, reading from stdin and printing to stdout
+func New() *Cli {
+	return &Cli{
+		Client: client.New(util.GetEnv("STRINGS_URL", "http://localhost:8080"), util.GetEnv("STRINGS_TOKEN", "")),
+		In:     os.Stdin,
+		Out:    os.Stdout,
+		Err:    os.Stderr,
+	}
+}
+
+// command is a subcommand, given the context of the run and its arguments
+type command func(ctx context.Context, args []string) error
+
+// Run runs the command named by args, e.g. `thread ls`
+func (c *Cli) Run(ctx context.Context, args []string) error {
+	commands := map[string]map[string]command{
+		"thread": {
+			"ls":     c.threadList,
+			"add":    c.threadAdd,
+			"rm":     c.threadRemove,
+			"rename": c.threadRename,
+		},
+		"string": {
+			"ls":      c.stringList,
+			"add":     c.stringAdd,
+			"mv":      c.stringMove,
+			"reorder": c.stringReorder,
+		},
+	}
+
+	if len(args) < 2 || commands[args[0]][args[1]] == nil {
+		fmt.Fprint(c.Err, Usage)
+		return ErrUsage
+	}
+	return commands[args[0]][args[1]](ctx, args[2:])
+}
+
+// flags makes the flag set of a command, with the --json flag every command
+// takes
+func (c *Cli) flags(name string) (*flag.FlagSet, *bool) {
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	flags.SetOutput(c.Err)
+	flags.Usage = func() {
+		fmt.Fprint(c.Err, Usage)
+	}
+	asJson := flags.Bool("json", false, "print JSON instead of a table")
+	return flags, asJson
+}
+
+// parse parses flags wherever they are among args, not only before the
+// first argument, and checks that exactly want arguments are left
+func parse(flags *flag.FlagSet, args []string, want int) ([]string, error) {
+	var positional []string
+	for {
+		err := flags.Parse(args)
+		if err != nil {
+			return nil, ErrUsage
+		}
+		args = flags.Args()
+		if len(args) == 0 {
+			break
+		}
+		positional = append(positional, args[0])
+		args = args[1:]
+	}
+
+	if len(positional) != want {
+		flags.Usage()
+		return nil, ErrUsage
+	}
+	return positional, nil
+}
+
+// printJson prints v indented
+func (c *Cli) printJson(v interface{}) error {
+	encoder := json.NewEncoder(c.Out)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// printTable prints a header and rows lined up in columns
+func (c *Cli) printTable(header []string, rows [][]string) error {
+	w := tabwriter.NewWriter(c.Out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, strings.Join(header, "\t"))
+	for _, row := range rows {
+		fmt.Fprintln(w, strings.Join(row, "\t"))
+	}
+	return w.Flush()
+}
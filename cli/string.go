@@ -0,0 +1,312 @@
+package cli
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/gofrs/uuid"
+	"github.com/orpheus/strings/core"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// stringList prints the strings of a thread, minor strings indented under
+// their parent
+func (c *Cli) stringList(ctx context.Context, args []string) error {
+	flags, asJson := c.flags("string ls")
+	thread := flags.String("thread", "", "the thread to list")
+	if _, err := parse(flags, args, 0); err != nil {
+		return err
+	}
+	if *thread == "" {
+		flags.Usage()
+		return ErrUsage
+	}
+
+	threadId, err := c.threadId(ctx, *thread)
+	if err != nil {
+		return err
+	}
+	list, err := c.Client.Strings.FindAllByThread(ctx, threadId)
+	if err != nil {
+		return err
+	}
+
+	if *asJson {
+		return c.printJson(list)
+	}
+	return c.printStrings(list...)
+}
+
+// stringAdd creates a string, last among its siblings
+func (c *Cli) stringAdd(ctx context.Context, args []string) error {
+	flags, asJson := c.flags("string add")
+	thread := flags.String("thread", "", "the thread to add the string to")
+	parent := flags.String("parent", "", "the string to nest the string under")
+	description := flags.String("description", "", "what the string is about")
+	kind := flags.String("kind", "", "actionable or active, the kind of the thread by default")
+	positional, err := parse(flags, args, 1)
+	if err != nil {
+		return err
+	}
+	if *thread == "" {
+		flags.Usage()
+		return ErrUsage
+	}
+
+	threadId, err := c.threadId(ctx, *thread)
+	if err != nil {
+		return err
+	}
+	parentId, err := optionalId(flags, "parent", *parent)
+	if err != nil {
+		return err
+	}
+
+	cs := core.String{
+		Name:        positional[0],
+		Thread:      threadId,
+		Parent:      parentId,
+		Description: *description,
+		Kind:        core.Kind(*kind),
+	}
+	created, err := c.Client.Strings.CreateOne(ctx, cs)
+	if err != nil {
+		return err
+	}
+
+	if *asJson {
+		return c.printJson(created)
+	}
+	return c.printStrings(created)
+}
+
+// stringMove moves a string to another thread, or puts it after or before
+// one of its siblings
+func (c *Cli) stringMove(ctx context.Context, args []string) error {
+	flags, asJson := c.flags("string mv")
+	thread := flags.String("thread", "", "the thread to move the string to")
+	order := flags.Int("order", -1, "the order to give the string in the thread, last by default")
+	after := flags.String("after", "", "the sibling to put the string after")
+	before := flags.String("before", "", "the sibling to put the string before")
+	positional, err := parse(flags, args, 1)
+	if err != nil {
+		return err
+	}
+	if (*thread == "") == (*after == "" && *before == "") {
+		fmt.Fprintln(c.Err, "give either --thread, or --after and/or --before")
+		return ErrUsage
+	}
+
+	id, err := uuid.FromString(positional[0])
+	if err != nil {
+		return fmt.Errorf("%q is not a string id", positional[0])
+	}
+
+	var moved core.String
+	if *thread != "" {
+		threadId, err := c.threadId(ctx, *thread)
+		if err != nil {
+			return err
+		}
+		if *order < 0 {
+			last, err := c.Client.Strings.FindAllByThread(ctx, threadId)
+			if err != nil {
+				return err
+			}
+			*order = len(siblings(last, uuid.NullUUID{}))
+		}
+		moved, err = c.Client.Strings.Move(ctx, id, threadId, *order)
+		if err != nil {
+			return err
+		}
+	} else {
+		afterId, err := optionalId(flags, "after", *after)
+		if err != nil {
+			return err
+		}
+		beforeId, err := optionalId(flags, "before", *before)
+		if err != nil {
+			return err
+		}
+		moved, err = c.Client.Strings.Reposition(ctx, id, afterId, beforeId)
+		if err != nil {
+			return err
+		}
+	}
+
+	if *asJson {
+		return c.printJson(moved)
+	}
+	return c.printStrings(moved)
+}
+
+// stringReorder lists a group of siblings and asks for their new order
+func (c *Cli) stringReorder(ctx context.Context, args []string) error {
+	flags, asJson := c.flags("string reorder")
+	thread := flags.String("thread", "", "the thread of the strings to reorder")
+	parent := flags.String("parent", "", "reorder the minor strings of this string instead of the top level")
+	if _, err := parse(flags, args, 0); err != nil {
+		return err
+	}
+	if *thread == "" {
+		flags.Usage()
+		return ErrUsage
+	}
+
+	threadId, err := c.threadId(ctx, *thread)
+	if err != nil {
+		return err
+	}
+	parentId, err := optionalId(flags, "parent", *parent)
+	if err != nil {
+		return err
+	}
+	all, err := c.Client.Strings.FindAllByThread(ctx, threadId)
+	if err != nil {
+		return err
+	}
+	group := siblings(all, parentId)
+	if len(group) < 2 {
+		fmt.Fprintln(c.Out, "Nothing to reorder")
+		return nil
+	}
+
+	for i, cs := range group {
+		fmt.Fprintf(c.Out, "%3d  %s\n", i+1, cs.Name)
+	}
+
+	input := bufio.NewScanner(c.In)
+	var positions []int
+	for {
+		fmt.Fprintf(c.Out, "New order, e.g. `%s`, or nothing to cancel: ", example(len(group)))
+		if !input.Scan() {
+			return input.Err()
+		}
+		line := strings.TrimSpace(input.Text())
+		if line == "" {
+			fmt.Fprintln(c.Out, "Cancelled")
+			return nil
+		}
+
+		positions, err = permutation(line, len(group))
+		if err == nil {
+			break
+		}
+		fmt.Fprintln(c.Out, err)
+	}
+
+	orders := make([]core.StringOrder, len(group))
+	for order, position := range positions {
+		orders[order] = core.StringOrder{Id: group[position-1].Id, Order: order}
+	}
+	err = c.Client.Strings.UpdateOrder(ctx, orders)
+	if err != nil {
+		return err
+	}
+
+	reordered, err := c.Client.Strings.FindAllByThread(ctx, threadId)
+	if err != nil {
+		return err
+	}
+	if *asJson {
+		return c.printJson(siblings(reordered, parentId))
+	}
+	return c.printStrings(siblings(reordered, parentId)...)
+}
+
+func (c *Cli) printStrings(list ...core.String) error {
+	children := make(map[uuid.NullUUID][]core.String)
+	ids := make(map[uuid.UUID]bool, len(list))
+	for _, cs := range list {
+		ids[cs.Id] = true
+	}
+	var roots []core.String
+	for _, cs := range list {
+		if cs.Parent.Valid && ids[cs.Parent.UUID] {
+			children[cs.Parent] = append(children[cs.Parent], cs)
+		} else {
+			roots = append(roots, cs)
+		}
+	}
+
+	var rows [][]string
+	var add func(group []core.String, depth int)
+	add = func(group []core.String, depth int) {
+		sortByOrder(group)
+		for _, cs := range group {
+			name := strings.Repeat("  ", depth) + cs.Name
+			rows = append(rows, []string{strconv.Itoa(cs.Order), name, string(cs.State), cs.Id.String()})
+			add(children[uuid.NullUUID{UUID: cs.Id, Valid: true}], depth+1)
+		}
+	}
+	add(roots, 0)
+
+	return c.printTable([]string{"ORDER", "NAME", "STATE", "ID"}, rows)
+}
+
+// siblings returns the strings with the given parent, in order
+func siblings(list []core.String, parent uuid.NullUUID) []core.String {
+	var group []core.String
+	for _, cs := range list {
+		if cs.Parent == parent {
+			group = append(group, cs)
+		}
+	}
+	sortByOrder(group)
+	return group
+}
+
+func sortByOrder(list []core.String) {
+	sort.SliceStable(list, func(i, j int) bool {
+		return list[i].Order < list[j].Order
+	})
+}
+
+// optionalId parses the value of an id flag, which may be left empty
+func optionalId(flags *flag.FlagSet, name string, value string) (uuid.NullUUID, error) {
+	if value == "" {
+		return uuid.NullUUID{}, nil
+	}
+	id, err := uuid.FromString(value)
+	if err != nil {
+		return uuid.NullUUID{}, fmt.Errorf("--%s of %s must be a string id", name, flags.Name())
+	}
+	return uuid.NullUUID{UUID: id, Valid: true}, nil
+}
+
+// permutation parses the positions of n strings in their new order, like
+// `3 1 2`, each counted from 1 and given once
+func permutation(line string, n int) ([]int, error) {
+	fields := strings.Fields(strings.ReplaceAll(line, ",", " "))
+	if len(fields) != n {
+		return nil, fmt.Errorf("give all %d positions", n)
+	}
+
+	seen := make(map[int]bool, n)
+	positions := make([]int, n)
+	for i, field := range fields {
+		position, err := strconv.Atoi(field)
+		if err != nil || position < 1 || position > n {
+			return nil, fmt.Errorf("%q is not a position between 1 and %d", field, n)
+		}
+		if seen[position] {
+			return nil, errors.New("give every position once")
+		}
+		seen[position] = true
+		positions[i] = position
+	}
+	return positions, nil
+}
+
+// example is the current order of n strings reversed, to show how to answer
+func example(n int) string {
+	positions := make([]string, n)
+	for i := range positions {
+		positions[i] = strconv.Itoa(n - i)
+	}
+	return strings.Join(positions, " ")
+}
@@ -0,0 +1,126 @@
+package cli
+
+import (
+	"context"
+	"fmt"
+	"github.com/gofrs/uuid"
+	"github.com/orpheus/strings/core"
+	"strconv"
+	"strings"
+)
+
+// threadList prints the active threads, or the archived ones
+func (c *Cli) threadList(ctx context.Context, args []string) error {
+	flags, asJson := c.flags("thread ls")
+	archived := flags.Bool("archived", false, "list the archived threads instead")
+	if _, err := parse(flags, args, 0); err != nil {
+		return err
+	}
+
+	threads, err := c.Client.Threads.FindAll(ctx, *archived)
+	if err != nil {
+		return err
+	}
+
+	if *asJson {
+		return c.printJson(threads)
+	}
+	return c.printThreads(threads...)
+}
+
+// threadAdd creates a thread
+func (c *Cli) threadAdd(ctx context.Context, args []string) error {
+	flags, asJson := c.flags("thread add")
+	description := flags.String("description", "", "what the thread is about")
+	kind := flags.String("kind", "", "actionable or active")
+	positional, err := parse(flags, args, 1)
+	if err != nil {
+		return err
+	}
+
+	thread := core.Thread{
+		Name:        positional[0],
+		Description: *description,
+		Kind:        core.Kind(*kind),
+	}
+	created, err := c.Client.Threads.CreateOne(ctx, thread)
+	if err != nil {
+		return err
+	}
+
+	if *asJson {
+		return c.printJson(created)
+	}
+	return c.printThreads(created)
+}
+
+// threadRemove moves a thread to the trash
+func (c *Cli) threadRemove(ctx context.Context, args []string) error {
+	flags, _ := c.flags("thread rm")
+	positional, err := parse(flags, args, 1)
+	if err != nil {
+		return err
+	}
+
+	id, err := c.threadId(ctx, positional[0])
+	if err != nil {
+		return err
+	}
+	return c.Client.Threads.DeleteById(ctx, id)
+}
+
+// threadRename renames a thread, keeping its description
+func (c *Cli) threadRename(ctx context.Context, args []string) error {
+	flags, asJson := c.flags("thread rename")
+	positional, err := parse(flags, args, 2)
+	if err != nil {
+		return err
+	}
+
+	id, err := c.threadId(ctx, positional[0])
+	if err != nil {
+		return err
+	}
+	thread, err := c.Client.Threads.FindById(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	thread.Name = positional[1]
+	renamed, err := c.Client.Threads.Update(ctx, thread)
+	if err != nil {
+		return err
+	}
+
+	if *asJson {
+		return c.printJson(renamed)
+	}
+	return c.printThreads(renamed)
+}
+
+// threadId returns the id of a thread given by id or by name. Names are
+// looked up among the active threads, ignoring case.
+func (c *Cli) threadId(ctx context.Context, thread string) (uuid.UUID, error) {
+	if id, err := uuid.FromString(thread); err == nil {
+		return id, nil
+	}
+
+	threads, err := c.Client.Threads.FindAll(ctx, false)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	for _, t := range threads {
+		if strings.EqualFold(strings.TrimSpace(t.Name), strings.TrimSpace(thread)) {
+			return t.Id, nil
+		}
+	}
+	return uuid.Nil, fmt.Errorf("there is no active thread named %q", thread)
+}
+
+func (c *Cli) printThreads(threads ...core.Thread) error {
+	rows := make([][]string, 0, len(threads))
+	for _, t := range threads {
+		rows = append(rows, []string{t.Id.String(), t.Name, string(t.Kind), strconv.FormatBool(t.Pinned)})
+	}
+	return c.printTable([]string{"ID", "NAME", "KIND", "PINNED"}, rows)
+}
@@ -0,0 +1,212 @@
+// Package client is a typed Go client for the HTTP api of the service. It
+// sends and receives the core types the controllers bind and render, and
+// only depends on core.
+//
+//	c := client.New("http://localhost:8080", token)
+//	threads, err := c.Threads.FindAll(ctx, false)
+//
+// Requests the server refuses fail with an *Error, which unwraps to the
+// domain error the server answered with, so errors.Is(err, core.ErrNotFound)
+// and errors.Is(err, core.ErrStringNameTaken) work as they do on the server.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/orpheus/strings/core"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Client talks to the server at BaseUrl as the user Token signs in
+type Client struct {
+	BaseUrl string
+	// Token is a session token or personal API key
+	Token string
+	Http  *http.Client
+	// Retries is how many times a request that may safely be repeated is
+	// retried after failing to reach the server or an answer of 429, 502,
+	// 503 or 504
+	Retries int
+	// Backoff is how long to wait before the first retry. It doubles with
+	// every retry after.
+	Backoff time.Duration
+
+	Threads *ThreadClient
+	Strings *StringClient
+}
+
+// New makes a client for the server at baseUrl, e.g. http://localhost:8080,
+// that retries failed requests up to 3 times
+func New(baseUrl string, token string) *Client {
+	c := &Client{
+		BaseUrl: strings.TrimRight(baseUrl, "/"),
+		Token:   token,
+		Http:    &http.Client{Timeout: 30 * time.Second},
+		Retries: 3,
+		Backoff: 200 * time.Millisecond,
+	}
+	c.Threads = &ThreadClient{client: c}
+	c.Strings = &StringClient{client: c}
+	return c
+}
+
+// Error is a request the server answered with an error status
+type Error struct {
+	Status int
+	// Domain is the error the server answered with, nil if the answer had no
+	// error body, e.g. from a proxy in front of the server
+	Domain *core.Error
+}
+
+func (e *Error) Error() string {
+	if e.Domain != nil {
+		return e.Domain.Message
+	}
+	return fmt.Sprintf("the server answered %d %s", e.Status, http.StatusText(e.Status))
+}
+
+// Unwrap returns the domain error, so errors.Is matches the core errors
+func (e *Error) Unwrap() error {
+	if e.Domain == nil {
+		return nil
+	}
+	return e.Domain
+}
+
+// idempotent are the methods of the requests that may be sent again
+var idempotent = map[string]bool{
+	http.MethodGet:    true,
+	http.MethodPut:    true,
+	http.MethodDelete: true,
+}
+
+// retryable are the statuses worth another try
+var retryable = map[int]bool{
+	http.StatusTooManyRequests:    true,
+	http.StatusBadGateway:         true,
+	http.StatusServiceUnavailable: true,
+	http.StatusGatewayTimeout:     true,
+}
+
+// do sends a request to the api and decodes the answer into out, unless out
+// is nil. Requests with an idempotent method are retried.
+func (c *Client) do(ctx context.Context, method string, path string, query url.Values, body interface{}, out interface{}) error {
+	retries := 0
+	if idempotent[method] {
+		retries = c.Retries
+	}
+	_, err := c.request(ctx, method, path, query, body, out, retries)
+	return err
+}
+
+// doOnce sends a request like do but never retries it, for the requests that
+// are not safe to repeat whatever their method
+func (c *Client) doOnce(ctx context.Context, method string, path string, query url.Values, body interface{}, out interface{}) error {
+	_, err := c.request(ctx, method, path, query, body, out, 0)
+	return err
+}
+
+// remove deletes what is at path, retrying like do. An earlier attempt may
+// have deleted it before its answer got lost, so a retry that finds nothing
+// there to delete succeeds too.
+func (c *Client) remove(ctx context.Context, path string) error {
+	attempts, err := c.request(ctx, http.MethodDelete, path, nil, nil, nil, c.Retries)
+	if attempts > 1 && errors.Is(err, core.ErrNotFound) {
+		return nil
+	}
+	return err
+}
+
+// request sends a request, retrying it up to retries times, and returns how
+// many times it was sent
+func (c *Client) request(ctx context.Context, method string, path string, query url.Values, body interface{}, out interface{}, retries int) (int, error) {
+	target := c.BaseUrl + "/api" + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	var encoded []byte
+	if body != nil {
+		var err error
+		encoded, err = json.Marshal(body)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	wait := c.Backoff
+	for attempt := 0; ; attempt++ {
+		res, err := c.send(ctx, method, target, encoded)
+		if err == nil && (!retryable[res.StatusCode] || attempt == retries) {
+			defer res.Body.Close()
+			return attempt + 1, decode(res, out)
+		}
+		if err != nil && (ctx.Err() != nil || attempt == retries) {
+			return attempt + 1, err
+		}
+		if res != nil {
+			res.Body.Close()
+		}
+
+		select {
+		case <-time.After(wait):
+			wait *= 2
+		case <-ctx.Done():
+			return attempt + 1, ctx.Err()
+		}
+	}
+}
+
+// send sends a single request
+func (c *Client) send(ctx context.Context, method string, target string, body []byte) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		reader = bytes.NewReader(body)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, target, reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.Token)
+	}
+	return c.Http.Do(req)
+}
+
+// errorResponse is the body the server answers failed requests with
+type errorResponse struct {
+	Code    core.Code   `json:"code"`
+	Message string      `json:"message"`
+	Details interface{} `json:"details,omitempty"`
+}
+
+// decode decodes a successful answer into out, or a failed one into an
+// *Error
+func decode(res *http.Response, out interface{}) error {
+	if res.StatusCode >= 400 {
+		var failure errorResponse
+		err := json.NewDecoder(res.Body).Decode(&failure)
+		if err != nil || failure.Code == "" {
+			return &Error{Status: res.StatusCode}
+		}
+		return &Error{
+			Status: res.StatusCode,
+			Domain: &core.Error{Code: failure.Code, Message: failure.Message, Details: failure.Details},
+		}
+	}
+
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(res.Body).Decode(out)
+}
@@ -0,0 +1,380 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/gin-gonic/gin"
+	"github.com/gofrs/uuid"
+	"github.com/orpheus/strings/api"
+	"github.com/orpheus/strings/core"
+	"github.com/orpheus/strings/infrastructure/logging"
+	"github.com/orpheus/strings/infrastructure/postgres/pgtest"
+	"github.com/orpheus/strings/infrastructure/server"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// flaky is a server that answers 503 to the first failures requests and
+// `null` to the rest, counting every request it gets
+type flaky struct {
+	*httptest.Server
+	failures int32
+	requests int32
+}
+
+func newFlaky(t *testing.T, failures int32) *flaky {
+	f := &flaky{failures: failures}
+	f.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&f.requests, 1) <= f.failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte("null"))
+	}))
+	t.Cleanup(f.Close)
+	return f
+}
+
+// newClient makes a client that retries without waiting long
+func newClient(baseUrl string) *Client {
+	c := New(baseUrl, "secret")
+	c.Backoff = time.Millisecond
+	return c
+}
+
+func TestRetriesOnlyRequestsThatMayBeRepeated(t *testing.T) {
+	ctx := context.Background()
+	id := uuid.Must(uuid.NewV4())
+	tests := []struct {
+		name    string
+		call    func(c *Client) error
+		retried bool
+	}{
+		{"GET", func(c *Client) error {
+			_, err := c.Threads.FindAll(ctx, false)
+			return err
+		}, true},
+		{"PUT", func(c *Client) error {
+			return c.Threads.UpdateOrder(ctx, []core.ThreadOrder{{Id: id, Order: 0}})
+		}, true},
+		{"DELETE", func(c *Client) error {
+			return c.Threads.DeleteById(ctx, id)
+		}, true},
+		{"POST", func(c *Client) error {
+			_, err := c.Threads.CreateOne(ctx, core.Thread{Name: "Health"})
+			return err
+		}, false},
+		{"PUT state", func(c *Client) error {
+			_, err := c.Strings.Transition(ctx, id, core.StateInProgress)
+			return err
+		}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			server := newFlaky(t, 2)
+			err := test.call(newClient(server.URL))
+
+			if test.retried {
+				if err != nil || atomic.LoadInt32(&server.requests) != 3 {
+					t.Errorf("the request was sent %d times and failed with %v, want 3 times and success", atomic.LoadInt32(&server.requests), err)
+				}
+				return
+			}
+			var failed *Error
+			if !errors.As(err, &failed) || failed.Status != http.StatusServiceUnavailable || atomic.LoadInt32(&server.requests) != 1 {
+				t.Errorf("the request was sent %d times and failed with %v, want once and 503", atomic.LoadInt32(&server.requests), err)
+			}
+		})
+	}
+}
+
+func TestDeletesWhoseAnswerGotLostSucceed(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&requests, 1) == 1 {
+			// the string is deleted but the answer never arrives
+			conn, _, err := w.(http.Hijacker).Hijack()
+			if err == nil {
+				conn.Close()
+			}
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(errorResponse{Code: core.ErrStringNotFound.Code, Message: core.ErrStringNotFound.Message})
+	}))
+	defer server.Close()
+	c := newClient(server.URL)
+
+	err := c.Strings.DeleteById(context.Background(), uuid.Must(uuid.NewV4()))
+	if err != nil || atomic.LoadInt32(&requests) != 2 {
+		t.Errorf("the delete was sent %d times and failed with %v, want twice and success", atomic.LoadInt32(&requests), err)
+	}
+
+	c.Retries = 0
+	err = c.Strings.DeleteById(context.Background(), uuid.Must(uuid.NewV4()))
+	if !errors.Is(err, core.ErrStringNotFound) {
+		t.Errorf("a delete that was not retried returned %v, want %v", err, core.ErrStringNotFound)
+	}
+}
+
+func TestGivesUpAfterTheLastRetry(t *testing.T) {
+	server := newFlaky(t, 100)
+	c := newClient(server.URL)
+	c.Retries = 2
+
+	_, err := c.Threads.FindAll(context.Background(), false)
+
+	var failed *Error
+	if !errors.As(err, &failed) || failed.Status != http.StatusServiceUnavailable || failed.Domain != nil {
+		t.Errorf("FindAll returned %v, want a 503 without a domain error", err)
+	}
+	if atomic.LoadInt32(&server.requests) != 3 {
+		t.Errorf("the request was sent %d times, want 3", atomic.LoadInt32(&server.requests))
+	}
+}
+
+func TestStopsRetryingWhenTheContextIsDone(t *testing.T) {
+	server := newFlaky(t, 100)
+	c := newClient(server.URL)
+	c.Backoff = time.Hour
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := c.Threads.FindAll(ctx, false)
+
+	if !errors.Is(err, context.DeadlineExceeded) || atomic.LoadInt32(&server.requests) != 1 {
+		t.Errorf("the request was sent %d times and failed with %v, want once and %v", atomic.LoadInt32(&server.requests), err, context.DeadlineExceeded)
+	}
+}
+
+func TestCancelsRequestsInFlight(t *testing.T) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err := newClient(server.URL).Threads.FindAll(ctx, false)
+
+	if !errors.Is(err, context.DeadlineExceeded) || atomic.LoadInt32(&requests) != 1 {
+		t.Errorf("the request was sent %d times and failed with %v, want once and %v", atomic.LoadInt32(&requests), err, context.DeadlineExceeded)
+	}
+}
+
+func TestSendsTheToken(t *testing.T) {
+	var authorization string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		authorization = r.Header.Get("Authorization")
+		w.Write([]byte("[]"))
+	}))
+	defer server.Close()
+
+	_, err := newClient(server.URL).Threads.FindAll(context.Background(), false)
+
+	if err != nil || authorization != "Bearer secret" {
+		t.Errorf("FindAll sent %q and returned %v, want %q", authorization, err, "Bearer secret")
+	}
+}
+
+func TestUnwrapsTheDomainErrorOfTheAnswer(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(api.RenderErrors(&logging.TmpLogger{}))
+	r.POST("/api/string", func(c *gin.Context) {
+		api.Fail(c, core.ErrStringNameTaken)
+	})
+	r.PUT("/api/string/updateOrder", func(c *gin.Context) {
+		api.Fail(c, core.ErrStringOrders.WithDetails([]core.OrderProblem{{Id: "x", Problem: "not a string id"}}))
+	})
+	server := httptest.NewServer(r)
+	defer server.Close()
+	c := newClient(server.URL)
+
+	_, err := c.Strings.CreateOne(context.Background(), core.String{Name: "Run", Thread: uuid.Must(uuid.NewV4())})
+	if !errors.Is(err, core.ErrStringNameTaken) || !errors.Is(err, core.ErrConflict) || errors.Is(err, core.ErrNotFound) {
+		t.Errorf("CreateOne returned %v, want %v", err, core.ErrStringNameTaken)
+	}
+	var failed *Error
+	if !errors.As(err, &failed) || failed.Status != http.StatusConflict {
+		t.Errorf("CreateOne returned %#v, want a 409", err)
+	}
+
+	err = c.Strings.UpdateOrder(context.Background(), []core.StringOrder{})
+	if !errors.Is(err, core.ErrStringOrders) || !errors.As(err, &failed) || failed.Domain.Details == nil {
+		t.Errorf("UpdateOrder returned %#v, want %v with details", err, core.ErrStringOrders)
+	}
+}
+
+// signUp registers a user on the server at baseUrl and returns a session
+// token for them
+func signUp(t *testing.T, baseUrl string) string {
+	credentials := core.Credentials{Username: pgtest.Unique("user"), Password: "correct horse"}
+	body, err := json.Marshal(credentials)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var session core.Session
+	for _, path := range []string{"/api/auth/register", "/api/auth/login"} {
+		res, err := http.Post(baseUrl+path, "application/json", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = json.NewDecoder(res.Body).Decode(&session)
+		res.Body.Close()
+		if err != nil || res.StatusCode != http.StatusOK {
+			t.Fatalf("%s answered %d: %v", path, res.StatusCode, err)
+		}
+	}
+	return session.Token
+}
+
+// TestEveryMethodAgainstTheServer calls every method of the client on the
+// server made by server.Construct
+func TestEveryMethodAgainstTheServer(t *testing.T) {
+	conn := pgtest.Connect(t)
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	server.Construct(r, conn)
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	c := New(srv.URL, signUp(t, srv.URL))
+	ctx := context.Background()
+	must := func(method string, err error) {
+		t.Helper()
+		if err != nil {
+			t.Fatalf("%s failed: %s", method, err)
+		}
+	}
+
+	health, err := c.Threads.CreateOne(ctx, core.Thread{Name: "Health"})
+	must("Threads.CreateOne", err)
+	work, err := c.Threads.CreateOne(ctx, core.Thread{Name: "Work"})
+	must("Threads.CreateOne", err)
+
+	threads, err := c.Threads.FindAll(ctx, false)
+	must("Threads.FindAll", err)
+	if len(threads) != 2 {
+		t.Errorf("Threads.FindAll returned %d threads, want 2", len(threads))
+	}
+
+	health.Name = "Body"
+	renamed, err := c.Threads.Update(ctx, health)
+	must("Threads.Update", err)
+	if renamed.Name != "Body" {
+		t.Errorf("Threads.Update returned %q, want %q", renamed.Name, "Body")
+	}
+
+	must("Threads.UpdateOrder", c.Threads.UpdateOrder(ctx, []core.ThreadOrder{{Id: work.Id, Order: 0}, {Id: health.Id, Order: 1}}))
+
+	pinned, err := c.Threads.SetPinned(ctx, health.Id, true)
+	must("Threads.SetPinned", err)
+	if !pinned.Pinned {
+		t.Errorf("Threads.SetPinned(true) left the thread unpinned")
+	}
+	_, err = c.Threads.SetPinned(ctx, health.Id, false)
+	must("Threads.SetPinned", err)
+
+	_, err = c.Threads.SetArchived(ctx, work.Id, true)
+	must("Threads.SetArchived", err)
+	archived, err := c.Threads.FindAll(ctx, true)
+	must("Threads.FindAll", err)
+	if len(archived) != 1 || archived[0].Id != work.Id {
+		t.Errorf("Threads.FindAll(archived) returned %+v, want the archived thread", archived)
+	}
+	_, err = c.Threads.SetArchived(ctx, work.Id, false)
+	must("Threads.SetArchived", err)
+
+	run, err := c.Strings.CreateOne(ctx, core.String{Name: "Run", Thread: health.Id})
+	must("Strings.CreateOne", err)
+	_, err = c.Strings.CreateOne(ctx, core.String{Name: " run ", Thread: health.Id})
+	if !errors.Is(err, core.ErrStringNameTaken) {
+		t.Errorf("Strings.CreateOne of a taken name returned %v, want %v", err, core.ErrStringNameTaken)
+	}
+	merged, err := c.Strings.FindOrCreate(ctx, core.String{Name: " run ", Thread: health.Id})
+	must("Strings.FindOrCreate", err)
+	if merged.Id != run.Id {
+		t.Errorf("Strings.FindOrCreate returned %s, want the existing string %s", merged.Id, run.Id)
+	}
+	stretch, err := c.Strings.CreateOne(ctx, core.String{Name: "Stretch", Thread: health.Id})
+	must("Strings.CreateOne", err)
+
+	all, err := c.Strings.FindAll(ctx)
+	must("Strings.FindAll", err)
+	inThread, err := c.Strings.FindAllByThread(ctx, health.Id)
+	must("Strings.FindAllByThread", err)
+	if len(all) != 2 || len(inThread) != 2 {
+		t.Errorf("Strings.FindAll and FindAllByThread returned %d and %d strings, want 2", len(all), len(inThread))
+	}
+
+	must("Strings.UpdateName", c.Strings.UpdateName(ctx, run.Id, "Sprint"))
+	must("Strings.UpdateDescription", c.Strings.UpdateDescription(ctx, run.Id, "fast"))
+	order := 1
+	updated, err := c.Strings.Update(ctx, run.Id, core.StringPatch{Order: &order})
+	must("Strings.Update", err)
+	if updated.Name != "Sprint" || updated.Description != "fast" || updated.Order != 1 {
+		t.Errorf("Strings.Update returned %+v, want Sprint, fast and order 1", updated)
+	}
+	must("Strings.UpdateOrder", c.Strings.UpdateOrder(ctx, []core.StringOrder{{Id: run.Id, Order: 0}, {Id: stretch.Id, Order: 1}}))
+
+	history, err := c.Strings.FindHistory(ctx, run.Id)
+	must("Strings.FindHistory", err)
+	if len(history) == 0 || history[0].Action != core.RevisionCreate {
+		t.Errorf("Strings.FindHistory returned %+v, want the creation first", history)
+	}
+
+	transitioned, err := c.Strings.Transition(ctx, run.Id, core.StateInProgress)
+	must("Strings.Transition", err)
+	transitions, err := c.Strings.FindTransitions(ctx, run.Id)
+	must("Strings.FindTransitions", err)
+	if transitioned.State != core.StateInProgress || len(transitions) != 1 {
+		t.Errorf("Strings.Transition returned %s with %d transitions, want %s and 1", transitioned.State, len(transitions), core.StateInProgress)
+	}
+
+	nested, err := c.Strings.Reparent(ctx, stretch.Id, uuid.NullUUID{UUID: run.Id, Valid: true})
+	must("Strings.Reparent", err)
+	tree, err := c.Strings.FindTree(ctx, run.Id)
+	must("Strings.FindTree", err)
+	if nested.Parent.UUID != run.Id || len(tree.Children) != 1 {
+		t.Errorf("Strings.FindTree returned %d minor strings after Reparent, want 1", len(tree.Children))
+	}
+	_, err = c.Strings.Reparent(ctx, stretch.Id, uuid.NullUUID{})
+	must("Strings.Reparent", err)
+
+	repositioned, err := c.Strings.Reposition(ctx, stretch.Id, uuid.NullUUID{}, uuid.NullUUID{UUID: run.Id, Valid: true})
+	must("Strings.Reposition", err)
+	if repositioned.Order != 0 {
+		t.Errorf("Strings.Reposition put the string at %d, want 0", repositioned.Order)
+	}
+
+	moved, err := c.Strings.Move(ctx, stretch.Id, work.Id, 0)
+	must("Strings.Move", err)
+	if moved.Thread != work.Id {
+		t.Errorf("Strings.Move left the string in %s, want %s", moved.Thread, work.Id)
+	}
+
+	must("Strings.DeleteById", c.Strings.DeleteById(ctx, stretch.Id))
+	left, err := c.Strings.FindAllByThread(ctx, work.Id)
+	must("Strings.FindAllByThread", err)
+	if len(left) != 0 {
+		t.Errorf("the thread has %d strings after Strings.DeleteById, want none", len(left))
+	}
+
+	must("Threads.DeleteById", c.Threads.DeleteById(ctx, health.Id))
+	_, err = c.Threads.FindById(ctx, health.Id)
+	if !errors.Is(err, core.ErrThreadNotFound) {
+		t.Errorf("Threads.FindById of a deleted thread returned %v, want %v", err, core.ErrThreadNotFound)
+	}
+}
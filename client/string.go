@@ -0,0 +1,167 @@
+package client
+
+import (
+	"context"
+	"github.com/gofrs/uuid"
+	"github.com/orpheus/strings/core"
+	"net/http"
+	"net/url"
+)
+
+// StringClient calls the `/string` routes
+type StringClient struct {
+	client *Client
+}
+
+// The request bodies of the string routes, as the string controller binds
+// them
+type (
+	stateBody struct {
+		State core.State `json:"state"`
+	}
+	parentBody struct {
+		Parent uuid.NullUUID `json:"parent"`
+	}
+	moveBody struct {
+		Thread uuid.UUID `json:"thread"`
+		Order  int       `json:"order"`
+	}
+	repositionBody struct {
+		After  uuid.NullUUID `json:"after"`
+		Before uuid.NullUUID `json:"before"`
+	}
+)
+
+// FindAll fetches every string the user can see
+func (s *StringClient) FindAll(ctx context.Context) ([]core.String, error) {
+	var strings []core.String
+	err := s.client.do(ctx, http.MethodGet, "/string", nil, nil, &strings)
+	return strings, err
+}
+
+// FindAllByThread fetches the strings of a thread
+func (s *StringClient) FindAllByThread(ctx context.Context, threadId uuid.UUID) ([]core.String, error) {
+	var strings []core.String
+	err := s.client.do(ctx, http.MethodGet, "/string", url.Values{"thread": {threadId.String()}}, nil, &strings)
+	return strings, err
+}
+
+// CreateOne creates a string last among its siblings. A name already taken
+// in the thread fails with core.ErrStringNameTaken.
+func (s *StringClient) CreateOne(ctx context.Context, cs core.String) (core.String, error) {
+	var created core.String
+	err := s.client.do(ctx, http.MethodPost, "/string", nil, cs, &created)
+	return created, err
+}
+
+// FindOrCreate creates a string like CreateOne, unless the thread already has
+// a string with its name. That string is returned instead.
+func (s *StringClient) FindOrCreate(ctx context.Context, cs core.String) (core.String, error) {
+	var created core.String
+	err := s.client.do(ctx, http.MethodPost, "/string", url.Values{"onConflict": {"merge"}}, cs, &created)
+	return created, err
+}
+
+// UpdateName renames a string
+func (s *StringClient) UpdateName(ctx context.Context, stringId uuid.UUID, name string) error {
+	query := url.Values{"id": {stringId.String()}, "name": {name}}
+	return s.client.do(ctx, http.MethodPut, "/string/updateName", query, nil, nil)
+}
+
+// UpdateDescription replaces the description of a string
+func (s *StringClient) UpdateDescription(ctx context.Context, stringId uuid.UUID, description string) error {
+	query := url.Values{"id": {stringId.String()}, "description": {description}}
+	return s.client.do(ctx, http.MethodPut, "/string/updateDescription", query, nil, nil)
+}
+
+// Update applies the non-nil fields of a patch to a string. An empty
+// description clears it.
+func (s *StringClient) Update(ctx context.Context, stringId uuid.UUID, patch core.StringPatch) (core.String, error) {
+	body := make(map[string]interface{})
+	if patch.Name != nil {
+		body["name"] = *patch.Name
+	}
+	if patch.Description != nil {
+		body["description"] = *patch.Description
+	}
+	if patch.Order != nil {
+		body["order"] = *patch.Order
+	}
+	if patch.Thread != nil {
+		body["thread"] = *patch.Thread
+	}
+
+	var updated core.String
+	err := s.client.do(ctx, http.MethodPatch, "/string/"+stringId.String(), nil, body, &updated)
+	return updated, err
+}
+
+// UpdateOrder saves a new order for every string of one thread, or every
+// minor string of one parent. Orders must run from 0 without gaps or
+// repeats, otherwise the error details list the problem with each id.
+func (s *StringClient) UpdateOrder(ctx context.Context, stringOrders []core.StringOrder) error {
+	return s.client.do(ctx, http.MethodPut, "/string/updateOrder", nil, stringOrders, nil)
+}
+
+// DeleteById moves a string and its minor strings to the trash. A retry
+// that finds the string already gone succeeds.
+func (s *StringClient) DeleteById(ctx context.Context, id uuid.UUID) error {
+	return s.client.remove(ctx, "/string/"+id.String())
+}
+
+// FindHistory fetches every revision of a string, oldest first
+func (s *StringClient) FindHistory(ctx context.Context, stringId uuid.UUID) ([]core.StringRevision, error) {
+	var revisions []core.StringRevision
+	err := s.client.do(ctx, http.MethodGet, "/string/"+stringId.String()+"/history", nil, nil, &revisions)
+	return revisions, err
+}
+
+// Transition moves a string to the next state of its lifecycle. It is never
+// retried: sent again after it went through, it would fail as a transition
+// from the state it already reached.
+func (s *StringClient) Transition(ctx context.Context, stringId uuid.UUID, to core.State) (core.String, error) {
+	var cs core.String
+	err := s.client.doOnce(ctx, http.MethodPut, "/string/"+stringId.String()+"/state", nil, stateBody{State: to}, &cs)
+	return cs, err
+}
+
+// FindTransitions fetches every lifecycle transition of a string, oldest
+// first
+func (s *StringClient) FindTransitions(ctx context.Context, stringId uuid.UUID) ([]core.StateTransition, error) {
+	var transitions []core.StateTransition
+	err := s.client.do(ctx, http.MethodGet, "/string/"+stringId.String()+"/transitions", nil, nil, &transitions)
+	return transitions, err
+}
+
+// FindTree fetches a string with its minor strings nested under it
+func (s *StringClient) FindTree(ctx context.Context, id uuid.UUID) (core.StringNode, error) {
+	var tree core.StringNode
+	err := s.client.do(ctx, http.MethodGet, "/string/"+id.String()+"/tree", nil, nil, &tree)
+	return tree, err
+}
+
+// Reparent nests a string under another string of its thread, or moves it to
+// the top level if parent is null
+func (s *StringClient) Reparent(ctx context.Context, stringId uuid.UUID, parent uuid.NullUUID) (core.String, error) {
+	var cs core.String
+	err := s.client.do(ctx, http.MethodPut, "/string/"+stringId.String()+"/parent", nil, parentBody{Parent: parent}, &cs)
+	return cs, err
+}
+
+// Move moves a string and its minor strings to the top level of a thread at
+// the given order
+func (s *StringClient) Move(ctx context.Context, stringId uuid.UUID, threadId uuid.UUID, order int) (core.String, error) {
+	move := moveBody{Thread: threadId, Order: order}
+	var cs core.String
+	err := s.client.do(ctx, http.MethodPut, "/string/"+stringId.String()+"/move", nil, move, &cs)
+	return cs, err
+}
+
+// Reposition puts a string right after the string after, right before the
+// string before, or between the two
+func (s *StringClient) Reposition(ctx context.Context, stringId uuid.UUID, after uuid.NullUUID, before uuid.NullUUID) (core.String, error) {
+	reposition := repositionBody{After: after, Before: before}
+	var cs core.String
+	err := s.client.do(ctx, http.MethodPost, "/string/"+stringId.String()+"/reposition", nil, reposition, &cs)
+	return cs, err
+}
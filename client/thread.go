@@ -0,0 +1,81 @@
+package client
+
+import (
+	"context"
+	"github.com/gofrs/uuid"
+	"github.com/orpheus/strings/core"
+	"net/http"
+	"net/url"
+)
+
+// ThreadClient calls the `/thread` routes
+type ThreadClient struct {
+	client *Client
+}
+
+// FindAll fetches the active threads, pinned first and then in order, or the
+// archived threads instead
+func (t *ThreadClient) FindAll(ctx context.Context, archived bool) ([]core.Thread, error) {
+	query := url.Values{}
+	if archived {
+		query.Set("archived", "true")
+	}
+	var threads []core.Thread
+	err := t.client.do(ctx, http.MethodGet, "/thread", query, nil, &threads)
+	return threads, err
+}
+
+// FindById fetches a single thread with its strings embedded in order
+func (t *ThreadClient) FindById(ctx context.Context, id uuid.UUID) (core.Thread, error) {
+	var thread core.Thread
+	err := t.client.do(ctx, http.MethodGet, "/thread/"+id.String(), nil, nil, &thread)
+	return thread, err
+}
+
+// CreateOne creates a thread from its name, description and kind
+func (t *ThreadClient) CreateOne(ctx context.Context, thread core.Thread) (core.Thread, error) {
+	var created core.Thread
+	err := t.client.do(ctx, http.MethodPost, "/thread", nil, thread, &created)
+	return created, err
+}
+
+// Update replaces the name and description of the thread with the id of the
+// given one
+func (t *ThreadClient) Update(ctx context.Context, thread core.Thread) (core.Thread, error) {
+	body := core.Thread{Name: thread.Name, Description: thread.Description}
+	var updated core.Thread
+	err := t.client.do(ctx, http.MethodPut, "/thread/"+thread.Id.String(), nil, body, &updated)
+	return updated, err
+}
+
+// UpdateOrder saves a new order for the threads
+func (t *ThreadClient) UpdateOrder(ctx context.Context, threadOrders []core.ThreadOrder) error {
+	return t.client.do(ctx, http.MethodPut, "/thread/updateOrder", nil, threadOrders, nil)
+}
+
+// SetPinned pins a thread to the top of the list or unpins it
+func (t *ThreadClient) SetPinned(ctx context.Context, id uuid.UUID, pinned bool) (core.Thread, error) {
+	return t.setFlag(ctx, "/thread/"+id.String()+"/pin", pinned)
+}
+
+// SetArchived archives a thread or brings it back into the list
+func (t *ThreadClient) SetArchived(ctx context.Context, id uuid.UUID, archived bool) (core.Thread, error) {
+	return t.setFlag(ctx, "/thread/"+id.String()+"/archive", archived)
+}
+
+// DeleteById moves a thread and its strings to the trash. A retry that
+// finds the thread already gone succeeds.
+func (t *ThreadClient) DeleteById(ctx context.Context, id uuid.UUID) error {
+	return t.client.remove(ctx, "/thread/"+id.String())
+}
+
+// setFlag sets a flag of a thread with PUT and clears it with DELETE
+func (t *ThreadClient) setFlag(ctx context.Context, path string, value bool) (core.Thread, error) {
+	method := http.MethodPut
+	if !value {
+		method = http.MethodDelete
+	}
+	var thread core.Thread
+	err := t.client.do(ctx, method, path, nil, nil, &thread)
+	return thread, err
+}
@@ -0,0 +1,49 @@
+package core
+
+import (
+	"github.com/gofrs/uuid"
+	"time"
+)
+
+// OrderPoint is the order a string held from `Date` until the next point
+type OrderPoint struct {
+	Order int       `json:"order"`
+	Date  time.Time `json:"date"`
+}
+
+// PriorityDrift describes how a string's order moved within a thread over
+// time. Orders are zero based, so the top 3 are orders 0 through 2.
+type PriorityDrift struct {
+	String            uuid.UUID    `json:"string"`
+	Thread            uuid.UUID    `json:"thread"`
+	Series            []OrderPoint `json:"series"`
+	AverageRank       float64      `json:"averageRank"`
+	DaysInTop3        float64      `json:"daysInTop3"`
+	Volatility        float64      `json:"volatility"`
+	LongestStableDays float64      `json:"longestStableDays"`
+}
+
+// LifecycleReport summarizes where the strings of each kind are in their
+// lifecycle and how quickly they get there
+type LifecycleReport struct {
+	Actionable ActionableReport `json:"actionable"`
+	Active     ActiveReport     `json:"active"`
+}
+
+// ActionableReport summarizes actionable strings. CompletionRate is the share
+// of them that are done.
+type ActionableReport struct {
+	Counts            map[State]int `json:"counts"`
+	Total             int           `json:"total"`
+	CompletionRate    float64       `json:"completionRate"`
+	AverageDaysToDone float64       `json:"averageDaysToDone"`
+}
+
+// ActiveReport summarizes active strings. FadeRate is the share of them that
+// are fading or dormant.
+type ActiveReport struct {
+	Counts               map[State]int `json:"counts"`
+	Total                int           `json:"total"`
+	FadeRate             float64       `json:"fadeRate"`
+	AverageDaysToDormant float64       `json:"averageDaysToDormant"`
+}
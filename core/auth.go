@@ -0,0 +1,54 @@
+package core
+
+import (
+	"github.com/gofrs/uuid"
+	"time"
+)
+
+var (
+	ErrInvalidCredentials = Unauthenticated("wrong username or password")
+	ErrUsernameTaken      = Conflict("this username is taken")
+	ErrWeakPassword       = Validation("password must be at least 8 characters long")
+	ErrTokenNotFound      = NotFound("token not found")
+)
+
+// TokenKind tells sessions, which expire, from API keys, which last until
+// they are revoked
+type TokenKind string
+
+const (
+	TokenSession TokenKind = "session"
+	TokenApiKey  TokenKind = "api_key"
+)
+
+// Token describes a bearer token a user signs requests with. The token itself
+// is only known to its holder; the service keeps a hash of it.
+type Token struct {
+	Id          uuid.UUID  `json:"id"`
+	Owner       uuid.UUID  `json:"owner"`
+	Kind        TokenKind  `json:"kind"`
+	Name        string     `json:"name,omitempty"`
+	DateCreated time.Time  `json:"dateCreated"`
+	ExpiresAt   *time.Time `json:"expiresAt,omitempty"`
+	LastUsedAt  *time.Time `json:"lastUsedAt,omitempty"`
+}
+
+// Credentials are what a user registers and signs in with
+type Credentials struct {
+	Username string `json:"username" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
+
+// Session is handed out on sign in. `Token` is only ever shown once.
+type Session struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expiresAt"`
+	User      User      `json:"user"`
+}
+
+// ApiKey is a long-lived token for scripts. `Key` is only shown when the key
+// is created.
+type ApiKey struct {
+	Token
+	Key string `json:"key,omitempty"`
+}
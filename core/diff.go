@@ -0,0 +1,42 @@
+package core
+
+import (
+	"github.com/gofrs/uuid"
+	"time"
+)
+
+// Diff is the set of changes made to threads and strings between two
+// points in time
+type Diff struct {
+	From           time.Time       `json:"from"`
+	To             time.Time       `json:"to"`
+	ThreadsAdded   []Thread        `json:"threadsAdded"`
+	ThreadsRemoved []Thread        `json:"threadsRemoved"`
+	StringsAdded   []String        `json:"stringsAdded"`
+	StringsRemoved []String        `json:"stringsRemoved"`
+	StringsRenamed []StringRename  `json:"stringsRenamed"`
+	StringsMoved   []StringMove    `json:"stringsMoved"`
+	Reordered      []ThreadReorder `json:"reordered"`
+}
+
+// StringRename records a string's name at the start and end of a Diff
+type StringRename struct {
+	Id   uuid.UUID `json:"id"`
+	From string    `json:"from"`
+	To   string    `json:"to"`
+}
+
+// StringMove records a string that ended a Diff in a different thread
+type StringMove struct {
+	Id         uuid.UUID `json:"id"`
+	FromThread uuid.UUID `json:"fromThread"`
+	ToThread   uuid.UUID `json:"toThread"`
+}
+
+// ThreadReorder lists the strings of a thread whose order changed within a
+// Diff, with their order at the start (`Before`) and end (`After`)
+type ThreadReorder struct {
+	Thread uuid.UUID     `json:"thread"`
+	Before []StringOrder `json:"before"`
+	After  []StringOrder `json:"after"`
+}
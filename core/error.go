@@ -0,0 +1,81 @@
+package core
+
+import "errors"
+
+// Code is the category of a domain error. It decides the status a request
+// failing with the error gets and is returned to clients as is.
+type Code string
+
+const (
+	CodeNotFound        Code = "not_found"
+	CodeConflict        Code = "conflict"
+	CodeValidation      Code = "validation"
+	CodeForbidden       Code = "forbidden"
+	CodeUnauthenticated Code = "unauthenticated"
+	CodeInternal        Code = "internal"
+)
+
+// Error is an error clients can act on, as opposed to a failure of the
+// service. Details, if any, are returned to clients along with the message.
+type Error struct {
+	Code    Code
+	Message string
+	Details interface{}
+	// category marks the errors that stand for a whole category, like
+	// ErrNotFound
+	category bool
+}
+
+// The categories of domain errors. errors.Is(err, ErrNotFound) holds for
+// every not found error, e.g. ErrStringNotFound.
+var (
+	ErrNotFound   = &Error{Code: CodeNotFound, Message: "not found", category: true}
+	ErrConflict   = &Error{Code: CodeConflict, Message: "conflict", category: true}
+	ErrValidation = &Error{Code: CodeValidation, Message: "invalid request", category: true}
+)
+
+func (e *Error) Error() string {
+	return e.Message
+}
+
+// Is makes an error match its category, and errors with details match the
+// error they were made from
+func (e *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == e.Code && (t.category || t.Message == e.Message)
+}
+
+// WithDetails returns a copy of the error carrying details
+func (e *Error) WithDetails(details interface{}) *Error {
+	return &Error{Code: e.Code, Message: e.Message, Details: details}
+}
+
+func NotFound(message string) *Error {
+	return &Error{Code: CodeNotFound, Message: message}
+}
+
+func Conflict(message string) *Error {
+	return &Error{Code: CodeConflict, Message: message}
+}
+
+func Validation(message string) *Error {
+	return &Error{Code: CodeValidation, Message: message}
+}
+
+func Forbidden(message string) *Error {
+	return &Error{Code: CodeForbidden, Message: message}
+}
+
+func Unauthenticated(message string) *Error {
+	return &Error{Code: CodeUnauthenticated, Message: message}
+}
+
+// AsError returns the domain error err is or wraps, or nil if it is a failure
+// of the service
+func AsError(err error) *Error {
+	var e *Error
+	if errors.As(err, &e) {
+		return e
+	}
+	return nil
+}
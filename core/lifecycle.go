@@ -0,0 +1,78 @@
+package core
+
+import (
+	"github.com/gofrs/uuid"
+	"time"
+)
+
+// Kind is the nature of a thread or string. Actionable strings are things to
+// be done, like goals. Active strings are forces present in one's life, like
+// feelings.
+type Kind string
+
+const (
+	KindActionable Kind = "actionable"
+	KindActive     Kind = "active"
+)
+
+// State is where a string is in the lifecycle of its Kind
+type State string
+
+const (
+	StateTodo       State = "todo"
+	StateInProgress State = "in-progress"
+	StateDone       State = "done"
+	StateAbandoned  State = "abandoned"
+
+	StateActive  State = "active"
+	StateFading  State = "fading"
+	StateDormant State = "dormant"
+)
+
+var (
+	ErrInvalidKind       = Validation("kind must be one of `actionable` or `active`")
+	ErrInvalidTransition = Validation("state transition is not allowed")
+)
+
+var initialStates = map[Kind]State{
+	KindActionable: StateTodo,
+	KindActive:     StateActive,
+}
+
+// transitions lists the states each state may move to
+var transitions = map[State][]State{
+	StateTodo:       {StateInProgress, StateAbandoned},
+	StateInProgress: {StateDone, StateAbandoned},
+	StateActive:     {StateFading},
+	StateFading:     {StateDormant},
+}
+
+// Valid reports whether k is a known Kind
+func (k Kind) Valid() bool {
+	_, ok := initialStates[k]
+	return ok
+}
+
+// InitialState is the state new strings of kind k start in
+func (k Kind) InitialState() State {
+	return initialStates[k]
+}
+
+// CanTransition reports whether a string may move from one state to another
+func CanTransition(from State, to State) bool {
+	for _, next := range transitions[from] {
+		if next == to {
+			return true
+		}
+	}
+	return false
+}
+
+// StateTransition records when a string moved from one state to another
+type StateTransition struct {
+	Id          uuid.UUID `json:"id"`
+	String      uuid.UUID `json:"string"`
+	From        State     `json:"from"`
+	To          State     `json:"to"`
+	DateCreated time.Time `json:"dateCreated"`
+}
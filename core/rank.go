@@ -0,0 +1,111 @@
+package core
+
+import (
+	"errors"
+	"github.com/gofrs/uuid"
+	"strings"
+)
+
+var (
+	ErrRankOrder   = errors.New("the rank before must sort before the rank after")
+	ErrReposition  = Validation("give the id of a string to put this one `after`, `before`, or both")
+	ErrNotSiblings = Validation("a string can only be put next to a string with the same thread and parent")
+	ErrNeighbors   = Validation("the string `after` must come before the string `before`")
+)
+
+// Siblings names a group of strings sharing a thread and parent, which are
+// ordered among each other
+type Siblings struct {
+	Thread uuid.UUID     `json:"thread"`
+	Parent uuid.NullUUID `json:"parent"`
+}
+
+// Strings are ordered among their siblings by rank, a key that sorts as plain
+// bytes. A string can be put between two others by giving it a rank between
+// theirs, without renumbering anything else. Ranks are read as base 36
+// fractions, so there is always room for another rank between two.
+const rankDigits = "0123456789abcdefghijklmnopqrstuvwxyz"
+
+// MaxRankLength is how long ranks may grow before the ranks of their
+// siblings are spread out again
+const MaxRankLength = 16
+
+// RankBetween returns a rank that sorts after before and before after. An
+// empty before means the start of the siblings and an empty after their end.
+func RankBetween(before string, after string) (string, error) {
+	if after != "" && before >= after {
+		return "", ErrRankOrder
+	}
+
+	var rank strings.Builder
+	for i := 0; ; i++ {
+		if after != "" && i >= len(before) && i >= len(after) {
+			// before and after only differ by trailing zeros
+			return "", ErrRankOrder
+		}
+
+		low := rankDigit(before, i)
+		high := len(rankDigits)
+		if after != "" {
+			high = rankDigit(after, i)
+		}
+
+		if low == high {
+			rank.WriteByte(rankDigits[low])
+			continue
+		}
+		if high-low > 1 {
+			rank.WriteByte(rankDigits[(low+high)/2])
+			return rank.String(), nil
+		}
+
+		// no digit fits between, so keep the digit of before and find room
+		// after the rest of it
+		rank.WriteByte(rankDigits[low])
+		rest, err := RankBetween(suffix(before, i+1), "")
+		if err != nil {
+			return "", err
+		}
+		rank.WriteString(rest)
+		return rank.String(), nil
+	}
+}
+
+// SpreadRanks returns n ranks in order, evenly spread out and as short as
+// they can be
+func SpreadRanks(n int) []string {
+	width, space := 1, len(rankDigits)
+	for space <= n {
+		width++
+		space *= len(rankDigits)
+	}
+
+	ranks := make([]string, n)
+	for i := range ranks {
+		value := (i + 1) * space / (n + 1)
+		digits := make([]byte, width)
+		for j := width - 1; j >= 0; j-- {
+			digits[j] = rankDigits[value%len(rankDigits)]
+			value /= len(rankDigits)
+		}
+		// trailing zeros would make two ranks equal as fractions but not as keys
+		ranks[i] = strings.TrimRight(string(digits), "0")
+	}
+	return ranks
+}
+
+// rankDigit returns the value of the i-th digit of a rank, which is 0 past
+// its end
+func rankDigit(rank string, i int) int {
+	if i >= len(rank) {
+		return 0
+	}
+	return strings.IndexByte(rankDigits, rank[i])
+}
+
+func suffix(s string, i int) string {
+	if i >= len(s) {
+		return ""
+	}
+	return s[i:]
+}
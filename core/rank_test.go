@@ -0,0 +1,93 @@
+package core
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRankBetween(t *testing.T) {
+	tests := []struct {
+		before string
+		after  string
+		want   string
+	}{
+		{"", "", "i"},
+		{"a", "", "n"},
+		{"", "a", "5"},
+		{"a", "c", "b"},
+		{"a", "a1", "a0i"},
+		// no digit fits between a and b, so the rank goes on after a
+		{"a", "b", "ai"},
+		{"az", "b", "azi"},
+		{"", "1", "0i"},
+		{"zz", "", "zzi"},
+	}
+	for _, test := range tests {
+		got, err := RankBetween(test.before, test.after)
+		if err != nil || got != test.want {
+			t.Errorf("RankBetween(%q, %q) returned %q, %v, want %q", test.before, test.after, got, err, test.want)
+		}
+	}
+}
+
+func TestRankBetweenRefusesRanksOutOfOrder(t *testing.T) {
+	tests := []struct {
+		before string
+		after  string
+	}{
+		{"a", "a"},
+		{"b", "a"},
+		// ranks that differ only by trailing zeros are the same fraction
+		{"a", "a0"},
+		{"a", "a00"},
+		{"", "0"},
+	}
+	for _, test := range tests {
+		got, err := RankBetween(test.before, test.after)
+		if err != ErrRankOrder {
+			t.Errorf("RankBetween(%q, %q) returned %q, %v, want %v", test.before, test.after, got, err, ErrRankOrder)
+		}
+	}
+}
+
+func TestRankBetweenAlwaysFindsRoom(t *testing.T) {
+	before, after := "a", "b"
+	for i := 0; i < 100; i++ {
+		rank, err := RankBetween(before, after)
+		if err != nil {
+			t.Fatalf("RankBetween(%q, %q) failed: %s", before, after, err)
+		}
+		if rank <= before || rank >= after || strings.HasSuffix(rank, "0") {
+			t.Fatalf("RankBetween(%q, %q) returned %q", before, after, rank)
+		}
+		// squeeze towards before, the worst case for the length of ranks
+		after = rank
+	}
+}
+
+func TestSpreadRanks(t *testing.T) {
+	for _, n := range []int{0, 1, 2, 35, 36, 37, 1000, 2000} {
+		ranks := SpreadRanks(n)
+		if len(ranks) != n {
+			t.Fatalf("SpreadRanks(%d) returned %d ranks", n, len(ranks))
+		}
+
+		width := 1
+		for space := len(rankDigits); space <= n; space *= len(rankDigits) {
+			width++
+		}
+		for i, rank := range ranks {
+			if rank == "" || len(rank) > width || strings.HasSuffix(rank, "0") {
+				t.Errorf("SpreadRanks(%d) returned %q at %d, want at most %d digits without trailing zeros", n, rank, i, width)
+			}
+			if i > 0 && ranks[i-1] >= rank {
+				t.Errorf("SpreadRanks(%d) returned %q before %q", n, ranks[i-1], rank)
+			}
+			if i > 0 {
+				if _, err := RankBetween(ranks[i-1], rank); err != nil {
+					t.Errorf("SpreadRanks(%d) left no room between %q and %q: %s", n, ranks[i-1], rank, err)
+				}
+			}
+		}
+	}
+}
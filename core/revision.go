@@ -0,0 +1,46 @@
+package core
+
+import (
+	"github.com/gofrs/uuid"
+	"time"
+)
+
+// RevisionAction describes the kind of change a revision captured
+type RevisionAction string
+
+const (
+	RevisionCreate   RevisionAction = "create"
+	RevisionUpdate   RevisionAction = "update"
+	RevisionRename   RevisionAction = "rename"
+	RevisionReorder  RevisionAction = "reorder"
+	RevisionDescribe RevisionAction = "describe"
+	RevisionDelete   RevisionAction = "delete"
+	RevisionRestore  RevisionAction = "restore"
+	RevisionState    RevisionAction = "state"
+	RevisionReparent RevisionAction = "reparent"
+	RevisionMove     RevisionAction = "move"
+)
+
+// StringRevision is an immutable record of a single change made to a string.
+// `Before` is nil for creations and `After` is nil for deletions.
+type StringRevision struct {
+	Id          uuid.UUID      `json:"id"`
+	String      uuid.UUID      `json:"string"`
+	Action      RevisionAction `json:"action"`
+	Before      *String        `json:"before"`
+	After       *String        `json:"after"`
+	Actor       uuid.NullUUID  `json:"actor"`
+	DateCreated time.Time      `json:"dateCreated"`
+}
+
+// ThreadRevision is an immutable record of a single change made to a thread.
+// `Before` is nil for creations and `After` is nil for deletions.
+type ThreadRevision struct {
+	Id          uuid.UUID      `json:"id"`
+	Thread      uuid.UUID      `json:"thread"`
+	Action      RevisionAction `json:"action"`
+	Before      *Thread        `json:"before"`
+	After       *Thread        `json:"after"`
+	Actor       uuid.NullUUID  `json:"actor"`
+	DateCreated time.Time      `json:"dateCreated"`
+}
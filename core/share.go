@@ -0,0 +1,65 @@
+package core
+
+import (
+	"github.com/gofrs/uuid"
+	"time"
+)
+
+var (
+	ErrForbidden          = Forbidden("you do not have permission to do this")
+	ErrInvalidRole        = Validation("role must be `viewer` or `editor`")
+	ErrMemberNotFound     = NotFound("member not found")
+	ErrAlreadyMember      = Conflict("this user can already see the thread")
+	ErrInvitationNotFound = NotFound("invitation not found")
+	ErrAlreadyInvited     = Conflict("this user was already invited to the thread")
+)
+
+// Role is what a user may do with a thread. Viewers read it, editors also
+// change its strings, name and description, and the owner manages everything
+// else, including who it is shared with.
+type Role string
+
+const (
+	RoleViewer Role = "viewer"
+	RoleEditor Role = "editor"
+	RoleOwner  Role = "owner"
+)
+
+var roleRanks = map[Role]int{
+	RoleViewer: 1,
+	RoleEditor: 2,
+	RoleOwner:  3,
+}
+
+// Shareable tells whether a thread can be shared with this role. There is
+// only ever one owner.
+func (r Role) Shareable() bool {
+	return r == RoleViewer || r == RoleEditor
+}
+
+// Includes tells whether this role is allowed everything the required role is
+func (r Role) Includes(required Role) bool {
+	return roleRanks[r] >= roleRanks[required]
+}
+
+// Member is a user a thread is shared with, or its owner
+type Member struct {
+	Thread      uuid.UUID `json:"thread"`
+	User        uuid.UUID `json:"user"`
+	Username    string    `json:"username"`
+	Role        Role      `json:"role"`
+	DateCreated time.Time `json:"dateCreated"`
+}
+
+// Invitation offers a user a role on a thread until they accept or decline it
+type Invitation struct {
+	Id          uuid.UUID  `json:"id"`
+	Thread      uuid.UUID  `json:"thread"`
+	ThreadName  string     `json:"threadName"`
+	Inviter     uuid.UUID  `json:"inviter"`
+	Invitee     uuid.UUID  `json:"invitee"`
+	Role        Role       `json:"role"`
+	DateCreated time.Time  `json:"dateCreated"`
+	AcceptedAt  *time.Time `json:"acceptedAt,omitempty"`
+	DeclinedAt  *time.Time `json:"declinedAt,omitempty"`
+}
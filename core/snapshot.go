@@ -0,0 +1,11 @@
+package core
+
+import "time"
+
+// Snapshot is the full set of threads and strings as they were at a given
+// point in time, reconstructed from their revisions
+type Snapshot struct {
+	At      time.Time `json:"at"`
+	Threads []Thread  `json:"threads"`
+	Strings []String  `json:"strings"`
+}
@@ -5,17 +5,55 @@ import (
 	"time"
 )
 
+var (
+	ErrStringNotFound  = NotFound("string not found")
+	ErrStringCycle     = Validation("a string can not be nested under itself or one of its minor strings")
+	ErrParentThread    = Validation("a string must be in the same thread as its parent")
+	ErrInvalidOrder    = Validation("order must not be negative")
+	ErrInvalidName     = Validation("name must not be blank")
+	ErrRestoreParent   = Conflict("the parent of this string is in the trash, restore it first")
+	ErrStringOrders    = Validation("orders must number every string among the same siblings from 0, without gaps or repeats")
+	ErrStringNameTaken = Conflict("this thread already has a string with this name")
+)
+
 type String struct {
-	Id           uuid.UUID `json:"id"`
-	Name         string    `json:"name" binding:"required"`
-	Order        int       `json:"order"`
-	Thread       uuid.UUID `json:"thread" binding:"required"`
-	Description  string    `json:"description,omitempty"`
-	DateCreated  time.Time `json:"dateCreated"`
-	DateModified time.Time `json:"dateModified"`
+	Id           uuid.UUID     `json:"id"`
+	Name         string        `json:"name" binding:"required"`
+	Order        int           `json:"order"`
+	Thread       uuid.UUID     `json:"thread" binding:"required"`
+	Owner        uuid.UUID     `json:"owner"`
+	Parent       uuid.NullUUID `json:"parent"`
+	Description  string        `json:"description,omitempty"`
+	Kind         Kind          `json:"kind"`
+	State        State         `json:"state"`
+	DateCreated  time.Time     `json:"dateCreated"`
+	DateModified time.Time     `json:"dateModified"`
+	DeletedAt    *time.Time    `json:"deletedAt,omitempty"`
 }
 
 type StringOrder struct {
 	Id    uuid.UUID `json:"id"`
 	Order int       `json:"order"`
 }
+
+// OrderProblem is why one entry of a string order update was rejected. They
+// are the details of ErrStringOrders.
+type OrderProblem struct {
+	Id      string `json:"id"`
+	Problem string `json:"problem"`
+}
+
+// StringPatch holds the fields of a string to change. Nil fields are left
+// as they are.
+type StringPatch struct {
+	Name        *string
+	Description *string
+	Order       *int
+	Thread      *uuid.UUID
+}
+
+// StringNode is a string together with its nested minor strings
+type StringNode struct {
+	String
+	Children []StringNode `json:"children"`
+}
@@ -5,10 +5,28 @@ import (
 	"time"
 )
 
+var (
+	ErrThreadNotFound  = NotFound("thread not found")
+	ErrRestoreThread   = Conflict("the thread of this string is in the trash, restore it first")
+	ErrThreadNameTaken = Conflict("you already have a thread with this name")
+)
+
 type Thread struct {
-	Id           uuid.UUID `json:"id"`
-	Name         string    `json:"name" binding:"required"`
-	Description  string    `json:"description"`
-	DateCreated  time.Time `json:"dateCreated"`
-	DateModified time.Time `json:"dateModified"`
+	Id           uuid.UUID  `json:"id"`
+	Name         string     `json:"name" binding:"required"`
+	Description  string     `json:"description"`
+	Kind         Kind       `json:"kind"`
+	Order        int        `json:"order"`
+	Pinned       bool       `json:"pinned"`
+	Archived     bool       `json:"archived"`
+	Owner        uuid.UUID  `json:"owner"`
+	DateCreated  time.Time  `json:"dateCreated"`
+	DateModified time.Time  `json:"dateModified"`
+	DeletedAt    *time.Time `json:"deletedAt,omitempty"`
+	Strings      []String   `json:"strings,omitempty"`
+}
+
+type ThreadOrder struct {
+	Id    uuid.UUID `json:"id"`
+	Order int       `json:"order"`
 }
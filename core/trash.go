@@ -0,0 +1,11 @@
+package core
+
+var ErrNotInTrash = NotFound("nothing with this id is in the trash")
+
+// Trash holds the threads and strings that have been deleted but not yet
+// purged. Deleted threads carry the strings that were deleted along with
+// them, `Strings` holds the strings that were deleted on their own.
+type Trash struct {
+	Threads []Thread `json:"threads"`
+	Strings []String `json:"strings"`
+}
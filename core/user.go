@@ -0,0 +1,20 @@
+package core
+
+import (
+	"github.com/gofrs/uuid"
+	"time"
+)
+
+var (
+	ErrUserNotFound    = NotFound("user not found")
+	ErrUnauthenticated = Unauthenticated("you need to sign in")
+)
+
+// User is an account on the service. Every thread and string belongs to
+// exactly one user.
+type User struct {
+	Id           uuid.UUID `json:"id"`
+	Username     string    `json:"username" binding:"required"`
+	DateCreated  time.Time `json:"dateCreated"`
+	DateModified time.Time `json:"dateModified"`
+}
@@ -10,9 +10,9 @@ type Logger interface {
 type TmpLogger struct{}
 
 func (l *TmpLogger) Log(v ...interface{}) {
-	log.Println(v)
+	log.Println(v...)
 }
 
 func (l *TmpLogger) Logf(format string, v ...interface{}) {
-	log.Printf(format, v)
+	log.Printf(format, v...)
 }
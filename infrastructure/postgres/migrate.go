@@ -2,64 +2,414 @@ package postgres
 
 import (
 	"context"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
 	"fmt"
 	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/orpheus/strings/util"
-	"io/ioutil"
+	"io/fs"
 	"log"
 	"os"
 	"regexp"
+	"sort"
+	"strconv"
 	"strings"
+	"time"
 )
 
-func Migrate(conn *pgxpool.Pool) {
-	defaultSqlPath := "infrastructure/postgres/sql"
-	sqlPath := util.GetEnv("SQL_MIGRATION_SCRIPTS", defaultSqlPath)
-	files, err := os.ReadDir(sqlPath)
+// embedded holds the migration scripts in the binary
+//
+//go:embed sql/*.sql
+var embedded embed.FS
+
+// migrationLock is the key of the advisory lock held while migrating, so
+// that replicas starting together take turns
+const migrationLock = 7_271_832_090
+
+// migrationScript matches the names of migration scripts, capturing whether
+// it applies (V) or undoes (U) a migration, the parts of the version and the
+// description
+var migrationScript = regexp.MustCompile(`^([VU])(\d+)\.(\d+)\.(\d+)__(\w+)\.sql$`)
+
+// Migration is a versioned sql script that changes the schema, together with
+// the script that undoes it, if any
+type Migration struct {
+	Version     Version
+	Description string
+	File        string
+	Sql         string
+	Undo        string
+	Checksum    string
+}
+
+// Version is the `V<major>.<minor>.<patch>` version of a migration
+type Version [3]int
+
+// ParseVersion parses a version like `V1.2.0`, the leading V being optional
+func ParseVersion(s string) (Version, error) {
+	var v Version
+	_, err := fmt.Sscanf(strings.TrimPrefix(s, "V"), "%d.%d.%d", &v[0], &v[1], &v[2])
+	if err != nil || v.String() != "V"+strings.TrimPrefix(s, "V") {
+		return Version{}, fmt.Errorf("invalid version %q, expecting V<X>.<X>.<X>", s)
+	}
+	return v, nil
+}
+
+func (v Version) String() string {
+	return fmt.Sprintf("V%d.%d.%d", v[0], v[1], v[2])
+}
+
+// Less compares versions part by part, so V1.10.0 comes after V1.2.0
+func (v Version) Less(other Version) bool {
+	for i := range v {
+		if v[i] != other[i] {
+			return v[i] < other[i]
+		}
+	}
+	return false
+}
+
+// Migrate applies every migration script that has not been applied yet, in
+// version order, each in a transaction of its own. Applied versions are
+// recorded with a checksum in `schema_migrations`. It refuses to run if a
+// script changed after it was applied or an applied script is missing.
+func Migrate(conn *pgxpool.Pool) error {
+	return migrate(conn, func(ctx context.Context, c *pgx.Conn, migrations []Migration, applied map[string]appliedMigration) error {
+		return up(ctx, c, migrations, applied, nil)
+	})
+}
+
+// MigrateTo applies the migrations up to and including the target version
+// and undoes every applied migration after it, latest first
+func MigrateTo(conn *pgxpool.Pool, target Version) error {
+	return migrate(conn, func(ctx context.Context, c *pgx.Conn, migrations []Migration, applied map[string]appliedMigration) error {
+		found := false
+		for _, m := range migrations {
+			found = found || m.Version == target
+		}
+		if !found {
+			return fmt.Errorf("there is no migration %s", target)
+		}
+
+		err := down(ctx, c, migrations, applied, func(m Migration) bool {
+			return target.Less(m.Version)
+		})
+		if err != nil {
+			return err
+		}
+		return up(ctx, c, migrations, applied, &target)
+	})
+}
+
+// Rollback undoes the latest applied migration
+func Rollback(conn *pgxpool.Pool) error {
+	return migrate(conn, func(ctx context.Context, c *pgx.Conn, migrations []Migration, applied map[string]appliedMigration) error {
+		for i := len(migrations) - 1; i >= 0; i-- {
+			if _, ok := applied[migrations[i].Version.String()]; ok {
+				return undo(ctx, c, migrations[i])
+			}
+		}
+		log.Println("No migrations to undo")
+		return nil
+	})
+}
+
+// MigrationStatus is a migration script and whether and when it was applied
+type MigrationStatus struct {
+	Migration
+	DateApplied *time.Time
+	// Changed is set for applied migrations whose script changed since
+	Changed bool
+}
+
+// Status lists every migration script in version order with whether it was
+// applied
+func Status(conn *pgxpool.Pool) ([]MigrationStatus, error) {
+	var status []MigrationStatus
+	err := withLock(conn, func(ctx context.Context, c *pgx.Conn, migrations []Migration, applied map[string]appliedMigration) error {
+		for _, m := range migrations {
+			ms := MigrationStatus{Migration: m}
+			if a, ok := applied[m.Version.String()]; ok {
+				dateApplied := a.DateApplied
+				ms.DateApplied = &dateApplied
+				ms.Changed = a.Checksum != m.Checksum
+			}
+			status = append(status, ms)
+		}
+		return nil
+	})
+	return status, err
+}
+
+// migrate runs a change to the schema once the scripts have been checked
+// against the applied migrations
+func migrate(conn *pgxpool.Pool, change func(ctx context.Context, c *pgx.Conn, migrations []Migration, applied map[string]appliedMigration) error) error {
+	return withLock(conn, func(ctx context.Context, c *pgx.Conn, migrations []Migration, applied map[string]appliedMigration) error {
+		err := checkDrift(migrations, applied)
+		if err != nil {
+			return err
+		}
+		return change(ctx, c, migrations, applied)
+	})
+}
+
+// withLock loads the migration scripts and the applied migrations and calls
+// f while holding the migration lock
+func withLock(conn *pgxpool.Pool, f func(ctx context.Context, c *pgx.Conn, migrations []Migration, applied map[string]appliedMigration) error) error {
+	migrations, err := LoadMigrations(scripts())
+	if err != nil {
+		return err
+	}
+
+	ctx := context.Background()
+
+	// advisory locks belong to a session, so everything runs on one connection
+	c, err := conn.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer c.Release()
+
+	_, err = c.Exec(ctx, "select pg_advisory_lock($1)", migrationLock)
+	if err != nil {
+		return fmt.Errorf("failed to take the migration lock: %w", err)
+	}
+	defer c.Exec(ctx, "select pg_advisory_unlock($1)", migrationLock)
+
+	_, err = c.Exec(ctx, "create table if not exists schema_migrations ("+
+		"version VARCHAR PRIMARY KEY, "+
+		"description VARCHAR NOT NULL, "+
+		"checksum VARCHAR NOT NULL, "+
+		"date_applied TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP)")
 	if err != nil {
-		log.Fatalf("Can not find sql at sqlPath (%s): %s", sqlPath, err.Error())
+		return err
 	}
 
-	tx, err := conn.BeginTx(context.TODO(), pgx.TxOptions{})
+	applied, err := findApplied(ctx, c.Conn())
 	if err != nil {
-		log.Fatalln("Failed to create TX in migration: ", err)
+		return err
 	}
-	defer func() {
+
+	return f(ctx, c.Conn(), migrations, applied)
+}
+
+// up applies the migrations that have not been applied yet, up to and
+// including the target version if there is one
+func up(ctx context.Context, c *pgx.Conn, migrations []Migration, applied map[string]appliedMigration, target *Version) error {
+	for _, m := range migrations {
+		if target != nil && target.Less(m.Version) {
+			break
+		}
+		if _, ok := applied[m.Version.String()]; ok {
+			continue
+		}
+
+		log.Printf("Applying migration %s\n", m.File)
+		err := apply(ctx, c, m)
 		if err != nil {
-			tx.Rollback(context.TODO())
-			log.Println("Rolled back migration: ", err)
-		} else {
-			tx.Commit(context.TODO())
-			log.Println("Committed migrations")
+			return fmt.Errorf("failed to apply migration %s: %w", m.File, err)
 		}
-	}()
+	}
+	return nil
+}
+
+// down undoes the applied migrations matching the filter, latest first
+func down(ctx context.Context, c *pgx.Conn, migrations []Migration, applied map[string]appliedMigration, filter func(m Migration) bool) error {
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m := migrations[i]
+		if _, ok := applied[m.Version.String()]; !ok || !filter(m) {
+			continue
+		}
+
+		err := undo(ctx, c, m)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	versions := make(map[string]string)
+// scripts returns the migration scripts built into the binary, or those in
+// the directory SQL_MIGRATION_SCRIPTS names if it is set
+func scripts() fs.FS {
+	if sqlPath := util.GetEnv("SQL_MIGRATION_SCRIPTS", ""); sqlPath != "" {
+		return os.DirFS(sqlPath)
+	}
+	sub, err := fs.Sub(embedded, "sql")
+	if err != nil {
+		// the pattern of the embed directive guarantees the directory exists
+		panic(err)
+	}
+	return sub
+}
+
+// LoadMigrations reads the migration scripts at the root of fsys and sorts
+// them by version. Every undo script must belong to a migration.
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	files, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, fmt.Errorf("can not read migration scripts: %w", err)
+	}
+
+	scripts := make(map[Version]*Migration)
+	undos := make(map[Version]string)
 	for _, file := range files {
-		log.Println(file.Name(), file.IsDir())
-		fn := file.Name()
-		validator := regexp.MustCompile("^V\\d+.\\d+.\\d+__\\w+\\.(sql)$")
-		validMigrationScript := validator.MatchString(fn)
-		if !validMigrationScript {
-			log.Fatalf("Invalid migration script format: %s. Expecting: V<X>.<X>.<X>__<NAME>.sql", fn)
+		match := migrationScript.FindStringSubmatch(file.Name())
+		if file.IsDir() || match == nil {
+			return nil, fmt.Errorf("invalid migration script name: %s, expecting V<X>.<X>.<X>__<NAME>.sql "+
+				"or U<X>.<X>.<X>__<NAME>.sql", file.Name())
+		}
+
+		var version Version
+		for i := range version {
+			version[i], err = strconv.Atoi(match[i+2])
+			if err != nil {
+				return nil, fmt.Errorf("invalid version in migration script name: %s", file.Name())
+			}
+		}
+
+		content, err := fs.ReadFile(fsys, file.Name())
+		if err != nil {
+			return nil, err
+		}
+
+		if match[1] == "U" {
+			if _, ok := undos[version]; ok {
+				return nil, fmt.Errorf("there is more than one undo script for %s", version)
+			}
+			undos[version] = string(content)
+			continue
 		}
 
-		split := strings.Split(fn, "__")
-		version := split[0]
-		if _, ok := versions[version]; ok {
-			log.Fatalln("Duplicate versions found in migration sql scripts")
+		if other, ok := scripts[version]; ok {
+			return nil, fmt.Errorf("migration scripts %s and %s have the same version", other.File, file.Name())
 		}
-		versions[version] = fn
+		sum := sha256.Sum256(content)
+		scripts[version] = &Migration{
+			Version:     version,
+			Description: match[5],
+			File:        file.Name(),
+			Sql:         string(content),
+			Checksum:    hex.EncodeToString(sum[:]),
+		}
+	}
 
-		c, ioErr := ioutil.ReadFile(fmt.Sprintf("%s/%s", sqlPath, fn))
-		if ioErr != nil {
-			log.Fatalf("Could not read sql file: %s", fn)
+	for version, undo := range undos {
+		m, ok := scripts[version]
+		if !ok {
+			return nil, fmt.Errorf("undo script for %s has no migration script", version)
 		}
-		sql := string(c)
-		_, err = conn.Exec(context.Background(), sql)
+		m.Undo = undo
+	}
+
+	migrations := make([]Migration, 0, len(scripts))
+	for _, m := range scripts {
+		migrations = append(migrations, *m)
+	}
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].Version.Less(migrations[j].Version)
+	})
+	return migrations, nil
+}
+
+// appliedMigration is a row of `schema_migrations`
+type appliedMigration struct {
+	Checksum    string
+	DateApplied time.Time
+}
+
+// findApplied returns every applied migration by version
+func findApplied(ctx context.Context, conn *pgx.Conn) (map[string]appliedMigration, error) {
+	rows, err := conn.Query(ctx, "select version, checksum, date_applied from schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	found := make(map[string]appliedMigration)
+	for rows.Next() {
+		var version string
+		var a appliedMigration
+		err = rows.Scan(&version, &a.Checksum, &a.DateApplied)
 		if err != nil {
-			log.Fatalf("Failed to execute migartion script: %s", err.Error())
+			return nil, err
+		}
+		found[version] = a
+	}
+
+	return found, rows.Err()
+}
+
+// checkDrift makes sure every applied migration still has its script and
+// that the script is unchanged
+func checkDrift(migrations []Migration, applied map[string]appliedMigration) error {
+	scripts := make(map[string]Migration, len(migrations))
+	for _, m := range migrations {
+		scripts[m.Version.String()] = m
+	}
+
+	for version, a := range applied {
+		m, ok := scripts[version]
+		if !ok {
+			return fmt.Errorf("migration %s was applied but its script is missing", version)
 		}
+		if m.Checksum != a.Checksum {
+			return fmt.Errorf("migration script %s changed after it was applied, "+
+				"add a new migration instead of editing an applied one", m.File)
+		}
+	}
+
+	return nil
+}
+
+// apply runs a migration and records it in one transaction, so a failing
+// script leaves nothing behind
+func apply(ctx context.Context, conn *pgx.Conn, m Migration) error {
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, m.Sql)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.Exec(ctx, "insert into schema_migrations (version, description, checksum) values ($1, $2, $3)",
+		m.Version.String(), m.Description, m.Checksum)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
+
+// undo runs the undo script of a migration and forgets it was applied, in
+// one transaction
+func undo(ctx context.Context, conn *pgx.Conn, m Migration) error {
+	if m.Undo == "" {
+		return fmt.Errorf("migration %s can not be undone, it has no U%s script", m.File, m.File[1:])
+	}
+	log.Printf("Undoing migration %s\n", m.File)
+
+	tx, err := conn.Begin(ctx)
+	if err != nil {
+		return err
 	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, m.Undo)
+	if err != nil {
+		return fmt.Errorf("failed to undo migration %s: %w", m.File, err)
+	}
+
+	_, err = tx.Exec(ctx, "delete from schema_migrations where version = $1", m.Version.String())
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
 }
@@ -0,0 +1,49 @@
+// Package pgtest connects tests to the database named by TEST_DATABASE_URL,
+// e.g. postgresql://postgres@localhost:5432/strings_test. Tests that need a
+// database are skipped when it is not set. Tests share the database, so they
+// create users of their own rather than cleaning up after each other.
+package pgtest
+
+import (
+	"context"
+	"github.com/gofrs/uuid"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/orpheus/strings/infrastructure/postgres"
+	"github.com/orpheus/strings/util"
+	"sync"
+	"testing"
+)
+
+var (
+	once sync.Once
+	conn *pgxpool.Pool
+	err  error
+)
+
+// Connect returns a pool connected to the test database, migrated to the
+// latest version, or skips the test if there is none
+func Connect(tb testing.TB) *pgxpool.Pool {
+	tb.Helper()
+
+	url := util.GetEnv("TEST_DATABASE_URL", "")
+	if url == "" {
+		tb.Skip("TEST_DATABASE_URL is not set")
+	}
+
+	once.Do(func() {
+		conn, err = pgxpool.Connect(context.Background(), url)
+		if err == nil {
+			err = postgres.Migrate(conn)
+		}
+	})
+	if err != nil {
+		tb.Fatalf("failed to set up the test database: %s", err)
+	}
+	return conn
+}
+
+// Unique returns name followed by a random suffix, for names that must not
+// clash with those of other tests, like usernames
+func Unique(name string) string {
+	return name + "-" + uuid.Must(uuid.NewV4()).String()[:8]
+}
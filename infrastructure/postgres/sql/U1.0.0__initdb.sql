@@ -0,0 +1,6 @@
+--
+-- Undo Initial setup
+--
+DROP TABLE IF EXISTS string;
+
+DROP TABLE IF EXISTS thread;
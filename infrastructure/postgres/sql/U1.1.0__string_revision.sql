@@ -0,0 +1,4 @@
+--
+-- Undo String Revision
+--
+DROP TABLE IF EXISTS string_revision;
@@ -0,0 +1,4 @@
+--
+-- Undo Thread Revision
+--
+DROP TABLE IF EXISTS thread_revision;
@@ -0,0 +1,13 @@
+--
+-- Undo Kind
+--
+DROP TABLE IF EXISTS string_transition;
+
+ALTER TABLE string
+    DROP COLUMN IF EXISTS state;
+
+ALTER TABLE string
+    DROP COLUMN IF EXISTS kind;
+
+ALTER TABLE thread
+    DROP COLUMN IF EXISTS kind;
@@ -0,0 +1,9 @@
+--
+-- Undo String Parent
+--
+-- Minor strings end up at the top level of their thread.
+--
+DROP INDEX IF EXISTS idx_string_siblings;
+
+ALTER TABLE string
+    DROP COLUMN IF EXISTS parent;
@@ -0,0 +1,11 @@
+--
+-- Undo Thread Order
+--
+ALTER TABLE thread
+    DROP COLUMN IF EXISTS archived;
+
+ALTER TABLE thread
+    DROP COLUMN IF EXISTS pinned;
+
+ALTER TABLE thread
+    DROP COLUMN IF EXISTS "order";
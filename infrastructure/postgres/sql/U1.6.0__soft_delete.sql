@@ -0,0 +1,23 @@
+--
+-- Undo Soft Delete
+--
+-- Without a trash, whatever is in it is deleted for good. Revisions are kept.
+--
+DELETE
+FROM string
+WHERE deleted_at IS NOT NULL;
+
+DELETE
+FROM thread
+WHERE deleted_at IS NOT NULL;
+
+ALTER TABLE string
+    DROP COLUMN IF EXISTS deleted_at;
+
+DROP INDEX IF EXISTS idx_thread_name;
+
+ALTER TABLE thread
+    DROP COLUMN IF EXISTS deleted_at;
+
+ALTER TABLE thread
+    ADD CONSTRAINT thread_name_key UNIQUE (name);
@@ -0,0 +1,31 @@
+--
+-- Undo App User
+--
+-- Thread names go back to being unique among all threads that are not in
+-- the trash, as V1.6.0 left them.
+--
+DROP INDEX IF EXISTS idx_thread_name;
+
+CREATE UNIQUE INDEX idx_thread_name ON thread (name) WHERE deleted_at IS NULL;
+
+DROP INDEX IF EXISTS idx_string_revision_owner;
+DROP INDEX IF EXISTS idx_thread_revision_owner;
+DROP INDEX IF EXISTS idx_string_owner;
+DROP INDEX IF EXISTS idx_thread_owner;
+
+ALTER TABLE string_transition
+    DROP COLUMN IF EXISTS owner;
+
+ALTER TABLE string_revision
+    DROP COLUMN IF EXISTS owner;
+
+ALTER TABLE thread_revision
+    DROP COLUMN IF EXISTS owner;
+
+ALTER TABLE string
+    DROP COLUMN IF EXISTS owner;
+
+ALTER TABLE thread
+    DROP COLUMN IF EXISTS owner;
+
+DROP TABLE IF EXISTS app_user;
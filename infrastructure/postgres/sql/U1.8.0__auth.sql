@@ -0,0 +1,7 @@
+--
+-- Undo Auth
+--
+DROP TABLE IF EXISTS auth_token;
+
+ALTER TABLE app_user
+    DROP COLUMN IF EXISTS password_hash;
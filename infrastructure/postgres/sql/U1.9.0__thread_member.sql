@@ -0,0 +1,8 @@
+--
+-- Undo Thread Member
+--
+-- Shared threads are only visible to their owner again.
+--
+DROP TABLE IF EXISTS thread_invitation;
+
+DROP TABLE IF EXISTS thread_member;
@@ -0,0 +1,20 @@
+--
+-- Undo String Rank
+--
+-- Strings created since V2.0.0 have no `order` yet, so every order is
+-- counted from the ranks once more.
+--
+UPDATE string s
+SET "order" = r.position
+FROM (SELECT id,
+             ROW_NUMBER() OVER (PARTITION BY thread, parent ORDER BY rank, id) - 1 AS position
+      FROM string) r
+WHERE s.id = r.id;
+
+ALTER TABLE string
+    ALTER COLUMN "order" SET NOT NULL;
+
+DROP INDEX IF EXISTS idx_string_rank;
+
+ALTER TABLE string
+    DROP COLUMN IF EXISTS rank;
@@ -0,0 +1,8 @@
+--
+-- Undo String Name Unique
+--
+-- Strings renamed to tell them apart keep their new names.
+--
+DROP INDEX IF EXISTS idx_string_thread_name;
+
+DROP FUNCTION IF EXISTS string_name_key(TEXT);
@@ -0,0 +1,16 @@
+--
+-- Undo Revision Sequence
+--
+DROP INDEX IF EXISTS idx_thread_revision_thread;
+
+CREATE INDEX IF NOT EXISTS idx_thread_revision_thread ON thread_revision (thread, date_created);
+
+DROP INDEX IF EXISTS idx_string_revision_string;
+
+CREATE INDEX IF NOT EXISTS idx_string_revision_string ON string_revision (string, date_created);
+
+ALTER TABLE thread_revision
+    DROP COLUMN IF EXISTS seq;
+
+ALTER TABLE string_revision
+    DROP COLUMN IF EXISTS seq;
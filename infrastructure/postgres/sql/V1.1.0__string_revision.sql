@@ -0,0 +1,39 @@
+--
+-- String Revision
+--
+-- Every change made to a string is kept as an immutable revision holding the
+-- string as it was before and after the change. Revisions outlive the string
+-- they describe so there is intentionally no foreign key on `string`.
+--
+CREATE TABLE IF NOT EXISTS string_revision
+(
+    id           UUID PRIMARY KEY                  DEFAULT uuid_generate_v4(),
+    string       UUID                     NOT NULL,
+    action       VARCHAR                  NOT NULL,
+    before       JSONB,
+    after        JSONB,
+    actor        UUID,
+    date_created TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_string_revision_string ON string_revision (string, date_created);
+
+--
+-- Strings created before revisions existed get a `create` revision dated at
+-- their creation so their history has a starting point.
+--
+INSERT INTO string_revision (string, action, after, date_created)
+SELECT s.id,
+       'create',
+       jsonb_build_object(
+               'id', s.id,
+               'name', s.name,
+               'order', s."order",
+               'thread', s.thread,
+               'description', COALESCE(s.description, ''),
+               'dateCreated', s.date_created,
+               'dateModified', s.date_modified
+           ),
+       s.date_created
+FROM string s
+WHERE NOT EXISTS(SELECT 1 FROM string_revision r WHERE r.string = s.id);
@@ -0,0 +1,32 @@
+--
+-- Thread Revision
+--
+-- Mirrors `string_revision` so that threads can be reconstructed at any point
+-- in time alongside their strings.
+--
+CREATE TABLE IF NOT EXISTS thread_revision
+(
+    id           UUID PRIMARY KEY                  DEFAULT uuid_generate_v4(),
+    thread       UUID                     NOT NULL,
+    action       VARCHAR                  NOT NULL,
+    before       JSONB,
+    after        JSONB,
+    actor        UUID,
+    date_created TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_thread_revision_thread ON thread_revision (thread, date_created);
+
+INSERT INTO thread_revision (thread, action, after, date_created)
+SELECT t.id,
+       'create',
+       jsonb_build_object(
+               'id', t.id,
+               'name', t.name,
+               'description', COALESCE(t.description, ''),
+               'dateCreated', t.date_created,
+               'dateModified', t.date_modified
+           ),
+       t.date_created
+FROM thread t
+WHERE NOT EXISTS(SELECT 1 FROM thread_revision r WHERE r.thread = t.id);
@@ -0,0 +1,30 @@
+--
+-- Kind
+--
+-- Threads and strings are either `actionable` (goals) or `active` (feelings).
+-- Strings move through the lifecycle of their kind:
+--   actionable: todo -> in-progress -> done/abandoned
+--   active:     active -> fading -> dormant
+--
+ALTER TABLE thread
+    ADD COLUMN IF NOT EXISTS kind VARCHAR NOT NULL DEFAULT 'actionable';
+
+ALTER TABLE string
+    ADD COLUMN IF NOT EXISTS kind VARCHAR NOT NULL DEFAULT 'actionable';
+
+ALTER TABLE string
+    ADD COLUMN IF NOT EXISTS state VARCHAR NOT NULL DEFAULT 'todo';
+
+--
+-- String Transition
+--
+CREATE TABLE IF NOT EXISTS string_transition
+(
+    id           UUID PRIMARY KEY                  DEFAULT uuid_generate_v4(),
+    string       UUID                     NOT NULL,
+    from_state   VARCHAR                  NOT NULL,
+    to_state     VARCHAR                  NOT NULL,
+    date_created TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_string_transition_string ON string_transition (string, date_created);
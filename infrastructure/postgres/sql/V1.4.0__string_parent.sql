@@ -0,0 +1,11 @@
+--
+-- String Parent
+--
+-- Strings can be nested under another string of the same thread as minor
+-- strings. `order` is scoped to the strings sharing a parent.
+--
+ALTER TABLE string
+    ADD COLUMN IF NOT EXISTS parent UUID
+        CONSTRAINT fk_string_parent REFERENCES string (id);
+
+CREATE INDEX IF NOT EXISTS idx_string_siblings ON string (thread, parent, "order");
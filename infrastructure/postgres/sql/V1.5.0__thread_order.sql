@@ -0,0 +1,23 @@
+--
+-- Thread Order
+--
+-- Threads are ordered like strings and can be pinned to the top or archived
+-- to put away life areas that are no longer active without deleting them.
+--
+ALTER TABLE thread
+    ADD COLUMN IF NOT EXISTS "order" INT NOT NULL DEFAULT 0;
+
+ALTER TABLE thread
+    ADD COLUMN IF NOT EXISTS pinned BOOLEAN NOT NULL DEFAULT FALSE;
+
+ALTER TABLE thread
+    ADD COLUMN IF NOT EXISTS archived BOOLEAN NOT NULL DEFAULT FALSE;
+
+--
+-- Threads created before they had an order are ordered by creation date
+--
+UPDATE thread t
+SET "order" = o.position
+FROM (SELECT id, ROW_NUMBER() OVER (ORDER BY date_created) - 1 AS position FROM thread) o
+WHERE t.id = o.id
+  AND NOT EXISTS(SELECT 1 FROM thread WHERE "order" <> 0);
@@ -0,0 +1,20 @@
+--
+-- Soft Delete
+--
+-- Deleted threads and strings are kept in the trash until they are restored
+-- or purged. A thread and the strings deleted along with it share the same
+-- `deleted_at` so they can be restored together.
+--
+ALTER TABLE thread
+    ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+
+ALTER TABLE string
+    ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP WITH TIME ZONE;
+
+--
+-- Thread names only need to be unique among threads that are not in the trash
+--
+ALTER TABLE thread
+    DROP CONSTRAINT IF EXISTS thread_name_key;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_thread_name ON thread (name) WHERE deleted_at IS NULL;
@@ -0,0 +1,50 @@
+--
+-- App User
+--
+-- Every thread and string belongs to the user that created it. Revisions and
+-- transitions keep the owner of their subject so history stays private after
+-- a purge.
+--
+CREATE TABLE IF NOT EXISTS app_user
+(
+    id            UUID PRIMARY KEY                  DEFAULT uuid_generate_v4(),
+    username      VARCHAR                  NOT NULL,
+    date_created  TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    date_modified TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_app_user_username ON app_user (lower(username));
+
+--
+-- Owner
+--
+-- Rows created before users existed have no owner until the first user to
+-- sign up claims them.
+--
+ALTER TABLE thread
+    ADD COLUMN IF NOT EXISTS owner UUID CONSTRAINT fk_thread_owner REFERENCES app_user (id);
+
+ALTER TABLE string
+    ADD COLUMN IF NOT EXISTS owner UUID CONSTRAINT fk_string_owner REFERENCES app_user (id);
+
+ALTER TABLE thread_revision
+    ADD COLUMN IF NOT EXISTS owner UUID;
+
+ALTER TABLE string_revision
+    ADD COLUMN IF NOT EXISTS owner UUID;
+
+ALTER TABLE string_transition
+    ADD COLUMN IF NOT EXISTS owner UUID;
+
+CREATE INDEX IF NOT EXISTS idx_thread_owner ON thread (owner);
+CREATE INDEX IF NOT EXISTS idx_string_owner ON string (owner, thread);
+CREATE INDEX IF NOT EXISTS idx_thread_revision_owner ON thread_revision (owner, date_created);
+CREATE INDEX IF NOT EXISTS idx_string_revision_owner ON string_revision (owner, date_created);
+
+--
+-- Thread names only need to be unique among the threads of the same owner.
+-- The index keeps its name so that V1.6.0 leaves it alone.
+--
+DROP INDEX IF EXISTS idx_thread_name;
+
+CREATE UNIQUE INDEX idx_thread_name ON thread (owner, name) WHERE deleted_at IS NULL;
@@ -0,0 +1,30 @@
+--
+-- Auth
+--
+-- Users sign in with a bcrypt hashed password. Users created by a trusted
+-- proxy have no password.
+--
+ALTER TABLE app_user
+    ADD COLUMN IF NOT EXISTS password_hash VARCHAR;
+
+--
+-- Auth Token
+--
+-- Sessions and personal API keys are opaque bearer tokens. Only the SHA-256
+-- hash of a token is stored. Sessions expire, API keys last until revoked.
+--
+CREATE TABLE IF NOT EXISTS auth_token
+(
+    id           UUID PRIMARY KEY                  DEFAULT uuid_generate_v4(),
+    owner        UUID                     NOT NULL CONSTRAINT fk_auth_token_owner REFERENCES app_user (id) ON DELETE CASCADE,
+    kind         VARCHAR                  NOT NULL,
+    name         VARCHAR                  NOT NULL DEFAULT '',
+    token_hash   VARCHAR                  NOT NULL,
+    date_created TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at   TIMESTAMP WITH TIME ZONE,
+    last_used_at TIMESTAMP WITH TIME ZONE,
+    revoked_at   TIMESTAMP WITH TIME ZONE
+);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_auth_token_hash ON auth_token (token_hash);
+CREATE INDEX IF NOT EXISTS idx_auth_token_owner ON auth_token (owner, kind);
@@ -0,0 +1,40 @@
+--
+-- Thread Member
+--
+-- Threads can be shared with other users as a `viewer` or an `editor`. The
+-- owner of a thread is not a member; the thread's `owner` column names them.
+--
+CREATE TABLE IF NOT EXISTS thread_member
+(
+    thread       UUID                     NOT NULL CONSTRAINT fk_thread_member_thread REFERENCES thread (id) ON DELETE CASCADE,
+    member       UUID                     NOT NULL CONSTRAINT fk_thread_member_member REFERENCES app_user (id) ON DELETE CASCADE,
+    role         VARCHAR                  NOT NULL,
+    date_created TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (thread, member)
+);
+
+CREATE INDEX IF NOT EXISTS idx_thread_member_member ON thread_member (member);
+
+--
+-- Thread Invitation
+--
+-- A thread is shared once the invited user accepts. Declined and accepted
+-- invitations are kept.
+--
+CREATE TABLE IF NOT EXISTS thread_invitation
+(
+    id           UUID PRIMARY KEY                  DEFAULT uuid_generate_v4(),
+    thread       UUID                     NOT NULL CONSTRAINT fk_thread_invitation_thread REFERENCES thread (id) ON DELETE CASCADE,
+    inviter      UUID                     NOT NULL CONSTRAINT fk_thread_invitation_inviter REFERENCES app_user (id) ON DELETE CASCADE,
+    invitee      UUID                     NOT NULL CONSTRAINT fk_thread_invitation_invitee REFERENCES app_user (id) ON DELETE CASCADE,
+    role         VARCHAR                  NOT NULL,
+    date_created TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    accepted_at  TIMESTAMP WITH TIME ZONE,
+    declined_at  TIMESTAMP WITH TIME ZONE
+);
+
+CREATE INDEX IF NOT EXISTS idx_thread_invitation_invitee ON thread_invitation (invitee);
+
+-- only one pending invitation per user and thread
+CREATE UNIQUE INDEX IF NOT EXISTS idx_thread_invitation_pending ON thread_invitation (thread, invitee)
+    WHERE accepted_at IS NULL AND declined_at IS NULL;
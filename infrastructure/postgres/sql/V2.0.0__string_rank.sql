@@ -0,0 +1,28 @@
+--
+-- String Rank
+--
+-- Strings are ordered among their siblings by `rank`, a key compared byte by
+-- byte. Moving a string only changes its own rank; its `order` is counted
+-- from the ranks when it is read. The `order` column is no longer written.
+--
+ALTER TABLE string
+    ADD COLUMN IF NOT EXISTS rank VARCHAR COLLATE "C";
+
+--
+-- Strings created before they had a rank keep their order
+--
+UPDATE string s
+SET rank = r.rank
+FROM (SELECT id,
+             LPAD(TO_HEX(ROW_NUMBER() OVER (PARTITION BY thread, parent ORDER BY "order", date_created)), 8, '0') AS rank
+      FROM string) r
+WHERE s.id = r.id
+  AND s.rank IS NULL;
+
+ALTER TABLE string
+    ALTER COLUMN rank SET NOT NULL;
+
+ALTER TABLE string
+    ALTER COLUMN "order" DROP NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_string_rank ON string (thread, parent, rank) WHERE deleted_at IS NULL;
@@ -0,0 +1,89 @@
+--
+-- String Name Unique
+--
+-- The names of the strings in a thread are unique, ignoring case and how
+-- much whitespace surrounds and separates their words. `string_name_key` is
+-- the form names are compared in.
+--
+CREATE OR REPLACE FUNCTION string_name_key(name TEXT) RETURNS TEXT
+    LANGUAGE sql
+    IMMUTABLE
+AS
+$$
+SELECT lower(regexp_replace(btrim(name), '\s+', ' ', 'g'))
+$$;
+
+--
+-- Strings that already share a name with an older string of their thread
+-- are told apart by the start of their id, and a number too if that name is
+-- taken as well. Every rename is recorded as a `rename` revision without an
+-- actor.
+--
+DO
+$$
+    DECLARE
+        duplicate RECORD;
+        candidate TEXT;
+        attempt   INT;
+        renamed   JSONB;
+    BEGIN
+        FOR duplicate IN
+            SELECT s.id, s.thread, s.name, s.date_modified
+            FROM string s
+            WHERE s.deleted_at IS NULL
+              AND EXISTS(SELECT 1
+                         FROM string o
+                         WHERE o.thread = s.thread
+                           AND o.deleted_at IS NULL
+                           AND string_name_key(o.name) = string_name_key(s.name)
+                           AND (o.date_created, o.id) < (s.date_created, s.id))
+            ORDER BY s.date_created, s.id
+            LOOP
+                candidate := duplicate.name || ' (' || left(duplicate.id::TEXT, 8) || ')';
+                attempt := 1;
+                WHILE EXISTS(SELECT 1
+                             FROM string o
+                             WHERE o.thread = duplicate.thread
+                               AND o.deleted_at IS NULL
+                               AND o.id <> duplicate.id
+                               AND string_name_key(o.name) = string_name_key(candidate))
+                    LOOP
+                        attempt := attempt + 1;
+                        candidate := duplicate.name || ' (' || left(duplicate.id::TEXT, 8) || ' ' || attempt || ')';
+                    END LOOP;
+
+                UPDATE string SET name = candidate, date_modified = CURRENT_TIMESTAMP WHERE id = duplicate.id;
+
+                SELECT jsonb_build_object(
+                               'id', s.id,
+                               'name', s.name,
+                               'order', (SELECT count(*)
+                                         FROM string sibling
+                                         WHERE sibling.thread = s.thread
+                                           AND sibling.parent IS NOT DISTINCT FROM s.parent
+                                           AND sibling.deleted_at IS NULL
+                                           AND (sibling.rank, sibling.id) < (s.rank, s.id)),
+                               'thread', s.thread,
+                               'owner', s.owner,
+                               'parent', s.parent,
+                               'description', COALESCE(s.description, ''),
+                               'kind', s.kind,
+                               'state', s.state,
+                               'dateCreated', s.date_created,
+                               'dateModified', s.date_modified
+                           )
+                INTO renamed
+                FROM string s
+                WHERE s.id = duplicate.id;
+
+                INSERT INTO string_revision (string, action, before, after, owner)
+                VALUES (duplicate.id,
+                        'rename',
+                        renamed || jsonb_build_object('name', duplicate.name, 'dateModified', duplicate.date_modified),
+                        renamed,
+                        (renamed ->> 'owner')::UUID);
+            END LOOP;
+    END
+$$;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_string_thread_name ON string (thread, string_name_key(name)) WHERE deleted_at IS NULL;
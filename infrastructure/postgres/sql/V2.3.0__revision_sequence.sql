@@ -0,0 +1,21 @@
+--
+-- Revision Sequence
+--
+-- `date_created` is the start of the transaction that wrote a revision, so
+-- revisions written together share it. `seq` numbers revisions in the order
+-- they were written and breaks those ties. Revisions written before it
+-- existed are numbered in the order they are stored.
+--
+ALTER TABLE string_revision
+    ADD COLUMN IF NOT EXISTS seq BIGSERIAL;
+
+ALTER TABLE thread_revision
+    ADD COLUMN IF NOT EXISTS seq BIGSERIAL;
+
+DROP INDEX IF EXISTS idx_string_revision_string;
+
+CREATE INDEX IF NOT EXISTS idx_string_revision_string ON string_revision (string, date_created, seq);
+
+DROP INDEX IF EXISTS idx_thread_revision_thread;
+
+CREATE INDEX IF NOT EXISTS idx_thread_revision_thread ON thread_revision (thread, date_created, seq);
@@ -0,0 +1,169 @@
+package server
+
+import (
+	"encoding/json"
+	"github.com/gofrs/uuid"
+	"github.com/orpheus/strings/core"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// strangers are two users who share nothing. The owner has a thread with a
+// string, the stranger a thread of their own.
+type strangers struct {
+	owner          session
+	stranger       session
+	thread         core.Thread
+	cs             core.String
+	strangerThread core.Thread
+}
+
+func newStrangers(t *testing.T) strangers {
+	router := newRouter(t)
+	s := strangers{owner: signUp(t, router), stranger: signUp(t, router)}
+	s.owner.must(s.owner.do(http.MethodPost, "/api/thread", core.Thread{Name: "Health"}), &s.thread)
+	s.owner.must(s.owner.do(http.MethodPost, "/api/string", core.String{Name: "Run", Thread: s.thread.Id}), &s.cs)
+	s.stranger.must(s.stranger.do(http.MethodPost, "/api/thread", core.Thread{Name: "Work"}), &s.strangerThread)
+	return s
+}
+
+// assertNotFound fails the test unless the server answered with the given
+// not found error
+func assertNotFound(t *testing.T, res *httptest.ResponseRecorder, want *core.Error) {
+	t.Helper()
+	if res.Code != http.StatusNotFound {
+		t.Errorf("the server answered %d: %s, want %d", res.Code, res.Body, http.StatusNotFound)
+		return
+	}
+	var failure struct {
+		Code    core.Code `json:"code"`
+		Message string    `json:"message"`
+	}
+	if err := json.Unmarshal(res.Body.Bytes(), &failure); err != nil {
+		t.Fatalf("failed to decode %s: %s", res.Body, err)
+	}
+	if failure.Code != want.Code || failure.Message != want.Message {
+		t.Errorf("the server answered %+v, want %q", failure, want.Message)
+	}
+}
+
+// assertUntouched fails the test if the string of the owner changed since it
+// was created
+func (s strangers) assertUntouched(t *testing.T) {
+	t.Helper()
+	var history []core.StringRevision
+	s.owner.must(s.owner.do(http.MethodGet, "/api/string/"+s.cs.Id.String()+"/history", nil), &history)
+	if len(history) != 1 || history[0].Action != core.RevisionCreate {
+		t.Errorf("the string of the owner has revisions %+v, want only its creation", history)
+	}
+
+	var thread core.Thread
+	s.owner.must(s.owner.do(http.MethodGet, "/api/thread/"+s.thread.Id.String(), nil), &thread)
+	if thread.Name != s.thread.Name || len(thread.Strings) != 1 {
+		t.Errorf("the thread of the owner is %+v, want it as created with its string", thread)
+	}
+}
+
+func TestStrangersCanNotFindEachOthersThreads(t *testing.T) {
+	s := newStrangers(t)
+
+	assertNotFound(t, s.stranger.do(http.MethodGet, "/api/thread/"+s.thread.Id.String(), nil), core.ErrThreadNotFound)
+
+	var threads []core.Thread
+	s.stranger.must(s.stranger.do(http.MethodGet, "/api/thread", nil), &threads)
+	for _, thread := range threads {
+		if thread.Id == s.thread.Id {
+			t.Errorf("the threads of the stranger include the thread of the owner")
+		}
+	}
+}
+
+func TestStrangersCanNotFindEachOthersStrings(t *testing.T) {
+	s := newStrangers(t)
+	path := "/api/string/" + s.cs.Id.String()
+
+	assertNotFound(t, s.stranger.do(http.MethodGet, path+"/tree", nil), core.ErrStringNotFound)
+	assertNotFound(t, s.stranger.do(http.MethodGet, path+"/history", nil), core.ErrStringNotFound)
+
+	var strings []core.String
+	s.stranger.must(s.stranger.do(http.MethodGet, "/api/string", nil), &strings)
+	for _, cs := range strings {
+		if cs.Id == s.cs.Id {
+			t.Errorf("the strings of the stranger include the string of the owner")
+		}
+	}
+}
+
+func TestStrangersCanNotSeeEachOthersDrift(t *testing.T) {
+	s := newStrangers(t)
+	unknown := uuid.Must(uuid.NewV4()).String()
+
+	for _, id := range []string{s.cs.Id.String(), unknown} {
+		assertNotFound(t, s.stranger.do(http.MethodGet, "/api/analytics/string/"+id+"/drift", nil), core.ErrStringNotFound)
+	}
+	for _, id := range []string{s.thread.Id.String(), unknown} {
+		assertNotFound(t, s.stranger.do(http.MethodGet, "/api/analytics/thread/"+id+"/drift", nil), core.ErrThreadNotFound)
+	}
+}
+
+func TestStrangersCanNotSeeEachOthersSnapshots(t *testing.T) {
+	s := newStrangers(t)
+
+	var snapshot core.Snapshot
+	at := time.Now().Add(time.Minute).UTC().Format(time.RFC3339)
+	s.stranger.must(s.stranger.do(http.MethodGet, "/api/snapshot?at="+at, nil), &snapshot)
+
+	for _, thread := range snapshot.Threads {
+		if thread.Id == s.thread.Id {
+			t.Errorf("the snapshot of the stranger includes the thread of the owner")
+		}
+	}
+	for _, cs := range snapshot.Strings {
+		if cs.Id == s.cs.Id {
+			t.Errorf("the snapshot of the stranger includes the string of the owner")
+		}
+	}
+}
+
+func TestStrangersCanNotChangeEachOthersThreads(t *testing.T) {
+	s := newStrangers(t)
+	path := "/api/thread/" + s.thread.Id.String()
+
+	assertNotFound(t, s.stranger.do(http.MethodPut, path, core.Thread{Name: "Mine"}), core.ErrThreadNotFound)
+	assertNotFound(t, s.stranger.do(http.MethodDelete, path, nil), core.ErrThreadNotFound)
+
+	s.assertUntouched(t)
+}
+
+func TestStrangersCanNotChangeEachOthersStrings(t *testing.T) {
+	s := newStrangers(t)
+	path := "/api/string/" + s.cs.Id.String()
+
+	patch := map[string]interface{}{"name": "Mine"}
+	assertNotFound(t, s.stranger.do(http.MethodPatch, path, patch), core.ErrStringNotFound)
+	move := map[string]interface{}{"thread": s.strangerThread.Id, "order": 0}
+	assertNotFound(t, s.stranger.do(http.MethodPut, path+"/move", move), core.ErrStringNotFound)
+	assertNotFound(t, s.stranger.do(http.MethodDelete, path, nil), core.ErrStringNotFound)
+
+	s.assertUntouched(t)
+}
+
+func TestStrangersCanNotMoveStringsIntoEachOthersThreads(t *testing.T) {
+	s := newStrangers(t)
+	var own core.String
+	s.stranger.must(s.stranger.do(http.MethodPost, "/api/string", core.String{Name: "Write", Thread: s.strangerThread.Id}), &own)
+	path := "/api/string/" + own.Id.String()
+
+	move := map[string]interface{}{"thread": s.thread.Id, "order": 0}
+	assertNotFound(t, s.stranger.do(http.MethodPut, path+"/move", move), core.ErrThreadNotFound)
+	patch := map[string]interface{}{"thread": s.thread.Id}
+	assertNotFound(t, s.stranger.do(http.MethodPatch, path, patch), core.ErrThreadNotFound)
+
+	var thread core.Thread
+	s.owner.must(s.owner.do(http.MethodGet, "/api/thread/"+s.thread.Id.String(), nil), &thread)
+	if len(thread.Strings) != 1 {
+		t.Errorf("the thread of the owner has %d strings, want only its own", len(thread.Strings))
+	}
+}
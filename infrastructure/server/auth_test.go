@@ -0,0 +1,35 @@
+package server
+
+import (
+	"github.com/orpheus/strings/core"
+	"net/http"
+	"testing"
+)
+
+func TestUsingATokenAgainWithinAMinuteKeepsWhenItWasLastUsed(t *testing.T) {
+	s := signUp(t, newRouter(t))
+	var key core.ApiKey
+	s.must(s.do(http.MethodPost, "/api/auth/keys", map[string]string{"name": "backup"}), &key)
+	script := session{t: t, router: s.router, user: s.user, token: key.Key}
+
+	lastUsed := func() core.Token {
+		var keys []core.Token
+		s.must(s.do(http.MethodGet, "/api/auth/keys", nil), &keys)
+		for _, k := range keys {
+			if k.Id == key.Id {
+				return k
+			}
+		}
+		t.Fatalf("the key %s is gone", key.Id)
+		return core.Token{}
+	}
+
+	script.must(script.do(http.MethodGet, "/api/thread", nil), nil)
+	first := lastUsed()
+	script.must(script.do(http.MethodGet, "/api/thread", nil), nil)
+	second := lastUsed()
+
+	if first.LastUsedAt == nil || second.LastUsedAt == nil || !second.LastUsedAt.Equal(*first.LastUsedAt) {
+		t.Errorf("the key was last used at %v and then %v, want the first use kept", first.LastUsedAt, second.LastUsedAt)
+	}
+}
@@ -3,20 +3,78 @@ package server
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/orpheus/strings/api"
+	"github.com/orpheus/strings/api/analytics"
+	"github.com/orpheus/strings/api/auth"
+	"github.com/orpheus/strings/api/share"
+	"github.com/orpheus/strings/api/snapshot"
 	"github.com/orpheus/strings/api/string"
 	"github.com/orpheus/strings/api/thread"
+	"github.com/orpheus/strings/api/trash"
+	"github.com/orpheus/strings/api/user"
 	"github.com/orpheus/strings/infrastructure/logging"
 	"github.com/orpheus/strings/system"
+	"github.com/orpheus/strings/util"
+	"log"
+	"strconv"
+	"time"
 )
 
 func Construct(r *gin.Engine, conn *pgxpool.Pool) {
 	tmpLogger := &logging.TmpLogger{}
 
+	// every error response is rendered by this middleware, so it goes first
+	r.Use(api.RenderErrors(tmpLogger))
+
 	v1Router := r.Group("/api")
 	v1Router.GET("/health", func(c *gin.Context) {
 		c.JSON(200, "healthy")
 	})
 
+	unitOfWork := &UnitOfWork{
+		DB:     conn,
+		Logger: tmpLogger,
+	}
+
+	sessionTTL, err := strconv.Atoi(util.GetEnv("SESSION_TTL_HOURS", "720"))
+	if err != nil {
+		log.Fatalln("SESSION_TTL_HOURS must be a number of hours")
+	}
+
+	userRepository := &user.Repository{
+		DB:     conn,
+		Logger: tmpLogger,
+	}
+
+	authController := &auth.Controller{
+		Interactor: &system.AuthInteractor{
+			Users: userRepository,
+			Tokens: &auth.TokenRepository{
+				DB:     conn,
+				Logger: tmpLogger,
+			},
+			Transactor: unitOfWork,
+			SessionTTL: time.Duration(sessionTTL) * time.Hour,
+			Logger:     tmpLogger,
+		},
+		// only trust a proxy header when one is configured
+		ProxyHeader: util.GetEnv("AUTH_PROXY_HEADER", ""),
+		Logger:      tmpLogger,
+	}
+
+	userController := &user.Controller{
+		Interactor: &system.UserInteractor{
+			Repo:   userRepository,
+			Logger: tmpLogger,
+		},
+		Logger: tmpLogger,
+	}
+
+	authController.RegisterPublicRoutes(v1Router)
+
+	// everything registered on authorized requires a signed in user
+	authorized := v1Router.Group("", authController.Authenticate)
+
 	threadRepository := &thread.Repository{
 		DB:     conn,
 		Logger: tmpLogger,
@@ -29,9 +87,10 @@ func Construct(r *gin.Engine, conn *pgxpool.Pool) {
 
 	threadController := &thread.Controller{
 		Interactor: &system.ThreadInteractor{
-			Repo:          threadRepository,
-			StringDeleter: stringRepository,
-			Logger:        tmpLogger,
+			Repo:         threadRepository,
+			StringFinder: stringRepository,
+			Transactor:   unitOfWork,
+			Logger:       tmpLogger,
 		},
 		Logger: tmpLogger,
 	}
@@ -39,11 +98,61 @@ func Construct(r *gin.Engine, conn *pgxpool.Pool) {
 	stringController := string.StringController{
 		Interactor: &system.StringInteractor{
 			StringRepository: stringRepository,
+			Transactor:       unitOfWork,
+			Logger:           tmpLogger,
+		},
+		Logger: tmpLogger,
+	}
+
+	snapshotController := &snapshot.Controller{
+		Interactor: &system.SnapshotInteractor{
+			ThreadHistory: threadRepository,
+			StringHistory: stringRepository,
+			Logger:        tmpLogger,
+		},
+		Logger: tmpLogger,
+	}
+
+	analyticsController := &analytics.Controller{
+		Interactor: &system.AnalyticsInteractor{
+			RevisionFinder:   stringRepository,
+			Threads:          threadRepository,
+			StringFinder:     stringRepository,
+			TransitionFinder: stringRepository,
 			Logger:           tmpLogger,
 		},
-		Logger: nil,
+		Logger: tmpLogger,
+	}
+
+	shareController := &share.Controller{
+		Interactor: &system.ShareInteractor{
+			Repo: &share.Repository{
+				DB:     conn,
+				Logger: tmpLogger,
+			},
+			Threads:    threadRepository,
+			Transactor: unitOfWork,
+			Logger:     tmpLogger,
+		},
+		Logger: tmpLogger,
+	}
+
+	trashController := &trash.Controller{
+		Interactor: &system.TrashInteractor{
+			ThreadTrash: threadRepository,
+			StringTrash: stringRepository,
+			Transactor:  unitOfWork,
+			Logger:      tmpLogger,
+		},
+		Logger: tmpLogger,
 	}
 
-	threadController.RegisterRoutes(v1Router)
-	stringController.RegisterRoutes(v1Router)
+	authController.RegisterRoutes(authorized)
+	userController.RegisterRoutes(authorized)
+	threadController.RegisterRoutes(authorized)
+	stringController.RegisterRoutes(authorized)
+	shareController.RegisterRoutes(authorized)
+	snapshotController.RegisterRoutes(authorized)
+	analyticsController.RegisterRoutes(authorized)
+	trashController.RegisterRoutes(authorized)
 }
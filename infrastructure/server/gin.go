@@ -3,18 +3,44 @@ package server
 import (
 	"github.com/gin-contrib/cors"
 	"github.com/gin-gonic/gin"
+	"github.com/orpheus/strings/api"
+	"github.com/orpheus/strings/util"
+	"strings"
 	"time"
 )
 
+// NewGin creates the router. A handler that panics fails its request without
+// taking the server down. Browsers may only call the api from the origins
+// listed in CORS_ALLOW_ORIGINS, separated by commas. Credentials are only
+// allowed for listed origins, never for `*`.
 func NewGin() *gin.Engine {
-	r := gin.Default()
-	r.Use(cors.New(cors.Config{
-		AllowOrigins:     []string{"*"},
-		AllowMethods:     []string{"POST, DELETE, OPTIONS, GET, PUT"},
-		AllowHeaders:     []string{"Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With"},
-		ExposeHeaders:    []string{"Content-Length"},
-		AllowCredentials: true,
-		MaxAge:           12 * time.Hour,
-	}))
+	r := gin.New()
+	r.Use(gin.Logger(), gin.CustomRecovery(api.Recover))
+	r.Use(cors.New(corsConfig(util.GetEnv("CORS_ALLOW_ORIGINS", "*"))))
 	return r
 }
+
+func corsConfig(allowOrigins string) cors.Config {
+	config := cors.Config{
+		AllowMethods:  []string{"POST, DELETE, OPTIONS, GET, PUT, PATCH"},
+		AllowHeaders:  []string{"Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With"},
+		ExposeHeaders: []string{"Content-Length"},
+		MaxAge:        12 * time.Hour,
+	}
+
+	for _, origin := range strings.Split(allowOrigins, ",") {
+		origin = strings.TrimSpace(origin)
+		if origin == "*" {
+			config.AllowAllOrigins = true
+			config.AllowOrigins = nil
+			config.AllowCredentials = false
+			return config
+		}
+		if origin != "" {
+			config.AllowOrigins = append(config.AllowOrigins, origin)
+			config.AllowCredentials = true
+		}
+	}
+
+	return config
+}
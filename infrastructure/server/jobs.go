@@ -0,0 +1,59 @@
+package server
+
+import (
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/orpheus/strings/api/string"
+	"github.com/orpheus/strings/api/thread"
+	"github.com/orpheus/strings/infrastructure/logging"
+	"github.com/orpheus/strings/system"
+	"time"
+)
+
+// StartPurge permanently deletes threads and strings that have been in the
+// trash for longer than retention, checking every hour until stop is closed.
+// A retention of zero keeps the trash forever.
+func StartPurge(conn *pgxpool.Pool, retention time.Duration, stop <-chan struct{}) {
+	if retention <= 0 {
+		return
+	}
+
+	tmpLogger := &logging.TmpLogger{}
+
+	trashInteractor := &system.TrashInteractor{
+		ThreadTrash: &thread.Repository{
+			DB:     conn,
+			Logger: tmpLogger,
+		},
+		StringTrash: &string.StringRepository{
+			DB:     conn,
+			Logger: tmpLogger,
+		},
+		Transactor: &UnitOfWork{
+			DB:     conn,
+			Logger: tmpLogger,
+		},
+		Logger: tmpLogger,
+	}
+
+	go trashInteractor.RunPurge(retention, time.Hour, stop)
+}
+
+// StartRebalance spreads out string ranks that have grown too long, checking
+// every ten minutes until stop is closed
+func StartRebalance(conn *pgxpool.Pool, stop <-chan struct{}) {
+	tmpLogger := &logging.TmpLogger{}
+
+	stringInteractor := &system.StringInteractor{
+		StringRepository: &string.StringRepository{
+			DB:     conn,
+			Logger: tmpLogger,
+		},
+		Transactor: &UnitOfWork{
+			DB:     conn,
+			Logger: tmpLogger,
+		},
+		Logger: tmpLogger,
+	}
+
+	go stringInteractor.RunRebalance(10*time.Minute, stop)
+}
@@ -0,0 +1,122 @@
+package server
+
+import (
+	"github.com/orpheus/strings/core"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"testing"
+)
+
+// malformedIds are path params no route should fail on. The ids of a thread
+// and a string of the user are added to reach past the parsing of ids.
+var malformedIds = []string{
+	"",
+	"not-a-uuid",
+	"00000000-0000-0000-0000-000000000000",
+	"6ba7b810-9dad-11d1-80b4-00c04fd430c8",
+	"\x00",
+	"' or 1=1 --",
+	"-1",
+	strings.Repeat("9", 4096),
+}
+
+// malformedQueries are query strings no route should fail on
+var malformedQueries = []url.Values{
+	{"thread": {"not-a-uuid"}},
+	{"thread": {""}},
+	{"at": {"yesterday"}},
+	{"from": {""}, "to": {"tomorrow"}},
+	{"from": {"2026-01-01T00:00:00Z"}, "to": {"1970-01-01T00:00:00Z"}},
+	{"id": {"\x00"}, "name": {""}, "description": {strings.Repeat("a", 4096)}},
+	{"archived": {"maybe"}},
+	{"onConflict": {"merge"}, "thread": {"' or 1=1 --"}},
+}
+
+// malformedBodies are request bodies no route should fail on
+var malformedBodies = []string{
+	"",
+	"{",
+	"null",
+	"[]",
+	"[null]",
+	`"name"`,
+	`{"name": 1, "thread": true}`,
+	`{"name": "", "thread": "not-a-uuid", "order": -1, "kind": "passive"}`,
+	`{"state": "flying", "parent": "x", "role": "god", "username": ""}`,
+	`{"after": {}, "before": []}`,
+	`[{"id": "x", "order": "first"}, {"id": null, "order": 1e100}]`,
+}
+
+// signedOut are the routes that end the session the requests are sent with
+var signedOut = map[string]bool{
+	"POST /api/auth/logout":     true,
+	"DELETE /api/auth/sessions": true,
+}
+
+// TestRoutesSurviveMalformedRequests sends malformed ids, query strings and
+// bodies to every route. None may panic or fail with a server error, and the
+// server must still answer after.
+func TestRoutesSurviveMalformedRequests(t *testing.T) {
+	router := newRouter(t)
+	s := signUp(t, router)
+
+	var thread core.Thread
+	s.must(s.do(http.MethodPost, "/api/thread", core.Thread{Name: "Health"}), &thread)
+	var cs core.String
+	s.must(s.do(http.MethodPost, "/api/string", core.String{Name: "Run", Thread: thread.Id}), &cs)
+	ids := append([]string{thread.Id.String(), cs.Id.String()}, malformedIds...)
+
+	// deletes go last, so that the other routes reach the thread and string
+	routes := router.Routes()
+	sort.SliceStable(routes, func(i, j int) bool {
+		return routes[i].Method != http.MethodDelete && routes[j].Method == http.MethodDelete
+	})
+
+	for _, route := range routes {
+		if signedOut[route.Method+" "+route.Path] {
+			continue
+		}
+		for _, id := range ids {
+			path := withParams(route.Path, id)
+			for _, query := range malformedQueries {
+				assertNoServerError(t, s, route.Method, path+"?"+query.Encode(), "")
+			}
+			if route.Method == http.MethodGet {
+				continue
+			}
+			for _, body := range malformedBodies {
+				assertNoServerError(t, s, route.Method, path, body)
+			}
+		}
+	}
+
+	res := s.do(http.MethodGet, "/api/health", nil)
+	if res.Code != http.StatusOK {
+		t.Errorf("the health check answered %d after the malformed requests", res.Code)
+	}
+	res = s.do(http.MethodGet, "/api/thread", nil)
+	if res.Code != http.StatusOK {
+		t.Errorf("listing threads answered %d after the malformed requests: %s", res.Code, res.Body)
+	}
+}
+
+// withParams replaces every param of a route path with value
+func withParams(path string, value string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if strings.HasPrefix(segment, ":") || strings.HasPrefix(segment, "*") {
+			segments[i] = url.PathEscape(value)
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+func assertNoServerError(t *testing.T, s session, method string, path string, body string) {
+	t.Helper()
+	res := s.send(method, path, body)
+	if res.Code >= 500 {
+		t.Errorf("%s %s with body %.40q answered %d: %s", method, path, body, res.Code, res.Body)
+	}
+}
@@ -0,0 +1,87 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/gin-gonic/gin"
+	"github.com/orpheus/strings/api"
+	"github.com/orpheus/strings/core"
+	"github.com/orpheus/strings/infrastructure/postgres/pgtest"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newRouter constructs the server on the test database. A handler that
+// panics fails the test, while its request fails as it would in production.
+func newRouter(t *testing.T) *gin.Engine {
+	conn := pgtest.Connect(t)
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(gin.CustomRecovery(func(c *gin.Context, recovered interface{}) {
+		t.Errorf("%s %s panicked: %v", c.Request.Method, c.Request.URL, recovered)
+		api.Recover(c, recovered)
+	}))
+	Construct(r, conn)
+	return r
+}
+
+// session sends requests to a router as a signed in user
+type session struct {
+	t      *testing.T
+	router http.Handler
+	user   core.User
+	token  string
+}
+
+// signUp registers a new user and signs them in
+func signUp(t *testing.T, router http.Handler) session {
+	s := session{t: t, router: router}
+	credentials := core.Credentials{Username: pgtest.Unique("user"), Password: "correct horse"}
+	s.must(s.do(http.MethodPost, "/api/auth/register", credentials), &s.user)
+
+	var signedIn core.Session
+	s.must(s.do(http.MethodPost, "/api/auth/login", credentials), &signedIn)
+	s.token = signedIn.Token
+	return s
+}
+
+// do sends a request with body encoded as JSON, unless it is nil
+func (s session) do(method string, path string, body interface{}) *httptest.ResponseRecorder {
+	s.t.Helper()
+	var encoded bytes.Buffer
+	if body != nil {
+		if err := json.NewEncoder(&encoded).Encode(body); err != nil {
+			s.t.Fatalf("failed to encode the body of %s %s: %s", method, path, err)
+		}
+	}
+	return s.send(method, path, encoded.String())
+}
+
+// send sends a request with a body as is
+func (s session) send(method string, path string, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+	res := httptest.NewRecorder()
+	s.router.ServeHTTP(res, req)
+	return res
+}
+
+// must fails the test unless the request succeeded, and decodes the answer
+// into out unless out is nil
+func (s session) must(res *httptest.ResponseRecorder, out interface{}) {
+	s.t.Helper()
+	if res.Code >= 400 {
+		s.t.Fatalf("the server answered %d: %s", res.Code, res.Body)
+	}
+	if out == nil {
+		return
+	}
+	if err := json.Unmarshal(res.Body.Bytes(), out); err != nil {
+		s.t.Fatalf("failed to decode %s: %s", res.Body, err)
+	}
+}
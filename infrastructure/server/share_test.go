@@ -0,0 +1,45 @@
+package server
+
+import (
+	"github.com/orpheus/strings/core"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// shareThread makes member a viewer of a thread of owner
+func shareThread(t *testing.T, owner session, member session, thread core.Thread) {
+	t.Helper()
+	var invitation core.Invitation
+	invite := map[string]interface{}{"username": member.user.Username, "role": core.RoleViewer}
+	owner.must(owner.do(http.MethodPost, "/api/thread/"+thread.Id.String()+"/invitations", invite), &invitation)
+	member.must(member.do(http.MethodPost, "/api/invitation/"+invitation.Id.String()+"/accept", nil), nil)
+}
+
+func TestMembersSeeSharedThreadsInSnapshotsAndDiffs(t *testing.T) {
+	router := newRouter(t)
+	owner, member := signUp(t, router), signUp(t, router)
+	from := time.Now().Add(-time.Minute).UTC().Format(time.RFC3339)
+
+	var thread core.Thread
+	owner.must(owner.do(http.MethodPost, "/api/thread", core.Thread{Name: "Health"}), &thread)
+	var cs core.String
+	owner.must(owner.do(http.MethodPost, "/api/string", core.String{Name: "Run", Thread: thread.Id}), &cs)
+	shareThread(t, owner, member, thread)
+
+	var snapshot core.Snapshot
+	member.must(member.do(http.MethodGet, "/api/snapshot", nil), &snapshot)
+	if len(snapshot.Threads) != 1 || snapshot.Threads[0].Id != thread.Id {
+		t.Errorf("the snapshot of the member has threads %+v, want the shared thread", snapshot.Threads)
+	}
+	if len(snapshot.Strings) != 1 || snapshot.Strings[0].Id != cs.Id {
+		t.Errorf("the snapshot of the member has strings %+v, want the string of the shared thread", snapshot.Strings)
+	}
+
+	var diff core.Diff
+	member.must(member.do(http.MethodGet, "/api/diff?from="+url.QueryEscape(from), nil), &diff)
+	if len(diff.ThreadsAdded) != 1 || len(diff.StringsAdded) != 1 {
+		t.Errorf("the diff of the member added %+v and %+v, want the shared thread and its string", diff.ThreadsAdded, diff.StringsAdded)
+	}
+}
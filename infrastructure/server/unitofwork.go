@@ -0,0 +1,56 @@
+package server
+
+import (
+	"context"
+	"github.com/orpheus/strings/api"
+	"github.com/orpheus/strings/api/share"
+	"github.com/orpheus/strings/api/string"
+	"github.com/orpheus/strings/api/thread"
+	"github.com/orpheus/strings/api/user"
+	"github.com/orpheus/strings/infrastructure/logging"
+	"github.com/orpheus/strings/system"
+)
+
+// UnitOfWork implements system.Transactor on top of a pgx connection. The
+// repositories it hands out run on the transaction; transactions they begin
+// themselves become savepoints inside it.
+type UnitOfWork struct {
+	DB     api.PgxConn
+	Logger logging.Logger
+}
+
+func (u *UnitOfWork) Transact(work func(repos system.Repositories) error) error {
+	ctx := context.Background()
+
+	tx, err := u.DB.Begin(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Rollback is a no-op once the tx has been committed
+	defer tx.Rollback(ctx)
+
+	err = work(system.Repositories{
+		Threads: &thread.Repository{
+			DB:     tx,
+			Logger: u.Logger,
+		},
+		Strings: &string.StringRepository{
+			DB:     tx,
+			Logger: u.Logger,
+		},
+		Users: &user.Repository{
+			DB:     tx,
+			Logger: u.Logger,
+		},
+		Shares: &share.Repository{
+			DB:     tx,
+			Logger: u.Logger,
+		},
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit(ctx)
+}
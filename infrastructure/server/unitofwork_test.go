@@ -0,0 +1,189 @@
+package server
+
+import (
+	"errors"
+	"github.com/gofrs/uuid"
+	"github.com/orpheus/strings/api/string"
+	"github.com/orpheus/strings/api/thread"
+	"github.com/orpheus/strings/api/user"
+	"github.com/orpheus/strings/core"
+	"github.com/orpheus/strings/infrastructure/logging"
+	"github.com/orpheus/strings/infrastructure/postgres/pgtest"
+	"github.com/orpheus/strings/system"
+	"testing"
+	"time"
+)
+
+var errBroken = errors.New("the database went away")
+
+// breaking runs units of work with a repository method that fails, so that
+// a unit of work fails after it changed something
+type breaking struct {
+	*UnitOfWork
+	threadDelete  bool
+	stringRestore bool
+}
+
+func (b *breaking) Transact(work func(repos system.Repositories) error) error {
+	return b.UnitOfWork.Transact(func(repos system.Repositories) error {
+		if b.threadDelete {
+			repos.Threads = failingThreads{repos.Threads}
+		}
+		if b.stringRestore {
+			repos.Strings = failingStrings{repos.Strings}
+		}
+		return work(repos)
+	})
+}
+
+type failingThreads struct {
+	system.ThreadStore
+}
+
+func (failingThreads) DeleteById(uuid.UUID, uuid.UUID, time.Time) error {
+	return errBroken
+}
+
+type failingStrings struct {
+	system.StringStore
+}
+
+func (failingStrings) RestoreAllByThread(uuid.UUID, uuid.UUID, time.Time) ([]core.String, error) {
+	return nil, errBroken
+}
+
+// fixture is a user with a thread holding two strings in a test database
+type fixture struct {
+	unitOfWork *UnitOfWork
+	threads    *thread.Repository
+	strings    *string.StringRepository
+	user       core.User
+	thread     core.Thread
+}
+
+func newFixture(t *testing.T) fixture {
+	conn := pgtest.Connect(t)
+	logger := &logging.TmpLogger{}
+	f := fixture{
+		unitOfWork: &UnitOfWork{DB: conn, Logger: logger},
+		threads:    &thread.Repository{DB: conn, Logger: logger},
+		strings:    &string.StringRepository{DB: conn, Logger: logger},
+	}
+
+	var err error
+	f.user, err = (&user.Repository{DB: conn, Logger: logger}).CreateOne(core.User{Username: pgtest.Unique("owner")})
+	if err != nil {
+		t.Fatalf("failed to create a user: %s", err)
+	}
+	f.thread, err = f.threads.CreateOne(f.user.Id, core.Thread{Name: "Health", Kind: core.KindActionable})
+	if err != nil {
+		t.Fatalf("failed to create a thread: %s", err)
+	}
+	for _, cs := range []core.String{{Name: "Run"}, {Name: "Stretch"}} {
+		cs.Thread = f.thread.Id
+		cs.Kind = core.KindActionable
+		cs.State = core.KindActionable.InitialState()
+		_, err = f.strings.CreateOne(f.user.Id, cs)
+		if err != nil {
+			t.Fatalf("failed to create a string: %s", err)
+		}
+	}
+	return f
+}
+
+func (f fixture) threadInteractor(transactor system.Transactor) *system.ThreadInteractor {
+	return &system.ThreadInteractor{Repo: f.threads, StringFinder: f.strings, Transactor: transactor, Logger: &logging.TmpLogger{}}
+}
+
+func (f fixture) trashInteractor(transactor system.Transactor) *system.TrashInteractor {
+	return &system.TrashInteractor{ThreadTrash: f.threads, StringTrash: f.strings, Transactor: transactor, Logger: &logging.TmpLogger{}}
+}
+
+func TestThreadDeleteRollsBackWhenTheThreadFailsToDelete(t *testing.T) {
+	f := newFixture(t)
+	before, err := f.strings.FindAllByThread(f.user.Id, f.thread.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = f.threadInteractor(&breaking{UnitOfWork: f.unitOfWork, threadDelete: true}).DeleteById(f.user.Id, f.thread.Id)
+	if !errors.Is(err, errBroken) {
+		t.Fatalf("DeleteById returned %v, want %v", err, errBroken)
+	}
+
+	_, err = f.threads.FindById(f.user.Id, f.thread.Id)
+	if err != nil {
+		t.Errorf("the thread is gone: %s", err)
+	}
+	after, err := f.strings.FindAllByThread(f.user.Id, f.thread.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(after) != len(before) {
+		t.Errorf("the thread has %d strings left, want all %d", len(after), len(before))
+	}
+	for _, cs := range after {
+		history, err := f.strings.FindHistory(f.user.Id, cs.Id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(history) != 1 || history[0].Action != core.RevisionCreate {
+			t.Errorf("string %q has revisions %+v, want only its creation", cs.Name, history)
+		}
+	}
+}
+
+func TestThreadRestoreRollsBackWhenTheStringsFailToRestore(t *testing.T) {
+	f := newFixture(t)
+	err := f.threadInteractor(f.unitOfWork).DeleteById(f.user.Id, f.thread.Id)
+	if err != nil {
+		t.Fatalf("DeleteById failed: %s", err)
+	}
+
+	_, err = f.trashInteractor(&breaking{UnitOfWork: f.unitOfWork, stringRestore: true}).Restore(f.user.Id, f.thread.Id)
+	if !errors.Is(err, errBroken) {
+		t.Fatalf("Restore returned %v, want %v", err, errBroken)
+	}
+
+	_, err = f.threads.FindDeletedById(f.user.Id, f.thread.Id)
+	if err != nil {
+		t.Errorf("the thread left the trash: %s", err)
+	}
+	deleted, err := f.strings.FindDeleted(f.user.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inTrash := 0
+	for _, cs := range deleted {
+		if cs.Thread == f.thread.Id {
+			inTrash++
+		}
+	}
+	if inTrash != 2 {
+		t.Errorf("%d strings of the thread are in the trash, want both", inTrash)
+	}
+}
+
+func TestThreadRestoreBringsBackItsStrings(t *testing.T) {
+	f := newFixture(t)
+	err := f.threadInteractor(f.unitOfWork).DeleteById(f.user.Id, f.thread.Id)
+	if err != nil {
+		t.Fatalf("DeleteById failed: %s", err)
+	}
+
+	restored, err := f.trashInteractor(f.unitOfWork).Restore(f.user.Id, f.thread.Id)
+	if err != nil {
+		t.Fatalf("Restore failed: %s", err)
+	}
+
+	if len(restored.Threads) != 1 || len(restored.Threads[0].Strings) != 2 {
+		t.Fatalf("Restore returned %+v, want the thread with both strings", restored)
+	}
+	strings, err := f.strings.FindAllByThread(f.user.Id, f.thread.Id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(strings) != 2 {
+		t.Errorf("the thread has %d strings after the restore, want 2", len(strings))
+	}
+}
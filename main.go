@@ -1,16 +1,90 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"github.com/jackc/pgx/v4/pgxpool"
+	"github.com/orpheus/strings/cli"
 	"github.com/orpheus/strings/infrastructure/postgres"
 	"github.com/orpheus/strings/infrastructure/server"
 	"github.com/orpheus/strings/util"
 	"log"
+	"os"
+	"os/signal"
+	"strconv"
+	"text/tabwriter"
+	"time"
 )
 
+const usage = `Usage:
+  strings                          run the server
+  strings migrate up               apply every pending migration
+  strings migrate down             undo the latest migration
+  strings migrate status           list migrations and whether they are applied
+  strings migrate to <version>     apply or undo migrations until <version> is the latest
+  strings thread|string ...        manage threads and strings on a running server, see below
+
+` + cli.Usage
+
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "migrate":
+			migrate(os.Args[2:])
+		case "thread", "string":
+			// stop waiting on the server when interrupted
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+			err := cli.New().Run(ctx, os.Args[1:])
+			stop()
+			if err == cli.ErrUsage {
+				os.Exit(2)
+			}
+			if err != nil {
+				fmt.Fprintln(os.Stderr, "Error:", err)
+				os.Exit(1)
+			}
+		default:
+			fmt.Fprint(os.Stderr, usage)
+			os.Exit(2)
+		}
+		return
+	}
+
 	log.Println("Starting server...")
 
+	conn := connect()
+	defer conn.Close()
+
+	log.Println("Running migrations...")
+
+	err := postgres.Migrate(conn)
+	if err != nil {
+		log.Fatalln("Failed to migrate:", err)
+	}
+
+	log.Println("Creating gin server router...")
+
+	s := server.NewGin()
+	server.Construct(s, conn)
+
+	retentionDays, err := strconv.Atoi(util.GetEnv("TRASH_RETENTION_DAYS", "30"))
+	if err != nil {
+		log.Fatalln("TRASH_RETENTION_DAYS must be a number of days")
+	}
+	stopJobs := make(chan struct{})
+	defer close(stopJobs)
+	server.StartPurge(conn, time.Duration(retentionDays)*24*time.Hour, stopJobs)
+	server.StartRebalance(conn, stopJobs)
+
+	log.Println("Running server...")
+	err = s.Run()
+	if err != nil {
+		log.Fatalln("Error starting server")
+	} // listen and serve on 0.0.0.0:8080 (for windows "localhost:8080")
+}
+
+// connect opens the database configured by the DB_* environment variables
+func connect() *pgxpool.Pool {
 	dbUser := util.GetEnv("DB_USER", "postgres")
 	dbPass := util.GetEnv("DB_PASS", "")
 	dbName := util.GetEnv("DB_NAME", "strings")
@@ -20,21 +94,69 @@ func main() {
 	log.Println("Connecting database...")
 
 	jdbcUrl := fmt.Sprintf("postgresql://%s:%s@%s:%s/%s", dbUser, dbPass, dbHost, dbPort, dbName)
-	conn := postgres.NewPgxPool(jdbcUrl)
-	defer conn.Close()
+	return postgres.NewPgxPool(jdbcUrl)
+}
 
-	log.Println("Running migrations...")
+// migrate runs the `migrate` subcommand, changing the schema without starting
+// the server
+func migrate(args []string) {
+	want := 1
+	if len(args) > 0 && args[0] == "to" {
+		want = 2
+	}
+	if len(args) != want {
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
 
-	postgres.Migrate(conn)
+	var run func(conn *pgxpool.Pool) error
+	switch args[0] {
+	case "up":
+		run = postgres.Migrate
+	case "down":
+		run = postgres.Rollback
+	case "status":
+		run = printStatus
+	case "to":
+		version, err := postgres.ParseVersion(args[1])
+		if err != nil {
+			log.Fatalln(err)
+		}
+		run = func(conn *pgxpool.Pool) error {
+			return postgres.MigrateTo(conn, version)
+		}
+	default:
+		fmt.Fprint(os.Stderr, usage)
+		os.Exit(2)
+	}
 
-	log.Println("Creating gin server router...")
+	conn := connect()
+	defer conn.Close()
 
-	s := server.NewGin()
-	server.Construct(s, conn)
+	err := run(conn)
+	if err != nil {
+		log.Fatalln("Failed to migrate:", err)
+	}
+}
 
-	log.Println("Running server...")
-	err := s.Run()
+// printStatus prints a table of the migrations and when they were applied
+func printStatus(conn *pgxpool.Pool) error {
+	status, err := postgres.Status(conn)
 	if err != nil {
-		log.Fatalln("Error starting server")
-	} // listen and serve on 0.0.0.0:8080 (for windows "localhost:8080")
+		return err
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tDESCRIPTION\tAPPLIED")
+	for _, ms := range status {
+		applied := "pending"
+		if ms.DateApplied != nil {
+			applied = ms.DateApplied.Format(time.RFC3339)
+		}
+		if ms.Changed {
+			applied += " (script changed since)"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", ms.Version, ms.Description, applied)
+	}
+	return w.Flush()
 }
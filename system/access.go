@@ -0,0 +1,25 @@
+package system
+
+import (
+	"github.com/gofrs/uuid"
+	"github.com/orpheus/strings/core"
+)
+
+// RoleFinder tells what a user may do with a thread
+type RoleFinder interface {
+	FindRole(user uuid.UUID, id uuid.UUID) (core.Role, error)
+}
+
+// authorize checks that a user has at least the required role on a thread. It
+// returns ErrThreadNotFound if the user can not see the thread at all and
+// ErrForbidden if they can but not do this.
+func authorize(roles RoleFinder, user uuid.UUID, threadId uuid.UUID, required core.Role) error {
+	role, err := roles.FindRole(user, threadId)
+	if err != nil {
+		return err
+	}
+	if !role.Includes(required) {
+		return core.ErrForbidden
+	}
+	return nil
+}
@@ -0,0 +1,277 @@
+package system
+
+import (
+	"github.com/gofrs/uuid"
+	"github.com/orpheus/strings/core"
+	"github.com/orpheus/strings/infrastructure/logging"
+	"math"
+	"time"
+)
+
+type AnalyticsInteractor struct {
+	RevisionFinder   RevisionFinder
+	Threads          RoleFinder
+	StringFinder     StringFinder
+	TransitionFinder TransitionFinder
+	Logger           logging.Logger
+}
+
+// RevisionFinder fetches the string revisions of a user, oldest first
+type RevisionFinder interface {
+	FindHistory(user uuid.UUID, stringId uuid.UUID) ([]core.StringRevision, error)
+	FindHistoryByThread(user uuid.UUID, threadId uuid.UUID) ([]core.StringRevision, error)
+}
+
+type StringFinder interface {
+	FindAll(user uuid.UUID) ([]core.String, error)
+	FindAllByThread(user uuid.UUID, threadId uuid.UUID) ([]core.String, error)
+}
+
+type TransitionFinder interface {
+	FindTransitionsTo(user uuid.UUID, state core.State) ([]core.StateTransition, error)
+}
+
+const top = 3
+
+// StringDrift returns the priority drift of a single string within the thread
+// it currently belongs to, or last belonged to if it was deleted. Every string
+// has at least the revision of its creation, so a string without any is one
+// the user can not see.
+func (a *AnalyticsInteractor) StringDrift(user uuid.UUID, stringId uuid.UUID) (core.PriorityDrift, error) {
+	revisions, err := a.RevisionFinder.FindHistory(user, stringId)
+	if err != nil {
+		return core.PriorityDrift{}, err
+	}
+	if len(revisions) == 0 {
+		return core.PriorityDrift{}, core.ErrStringNotFound
+	}
+
+	var threadId uuid.UUID
+	for _, r := range revisions {
+		if r.After != nil {
+			threadId = r.After.Thread
+		}
+	}
+
+	return priorityDrift(stringId, threadId, revisions, time.Now()), nil
+}
+
+// ThreadDrift returns the priority drift of every string that has ever been
+// part of a thread, counting only the time each string spent in it. A thread
+// without any strings has no drift, if the user can see it at all.
+func (a *AnalyticsInteractor) ThreadDrift(user uuid.UUID, threadId uuid.UUID) ([]core.PriorityDrift, error) {
+	revisions, err := a.RevisionFinder.FindHistoryByThread(user, threadId)
+	if err != nil {
+		return nil, err
+	}
+	if len(revisions) == 0 {
+		err = authorize(a.Threads, user, threadId, core.RoleViewer)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var stringIds []uuid.UUID
+	byString := make(map[uuid.UUID][]core.StringRevision)
+	for _, r := range revisions {
+		if _, ok := byString[r.String]; !ok {
+			stringIds = append(stringIds, r.String)
+		}
+		byString[r.String] = append(byString[r.String], r)
+	}
+
+	now := time.Now()
+	drifts := make([]core.PriorityDrift, 0, len(stringIds))
+	for _, id := range stringIds {
+		drifts = append(drifts, priorityDrift(id, threadId, byString[id], now))
+	}
+
+	return drifts, nil
+}
+
+// Lifecycle reports on the lifecycle of every string of a user, or only the
+// strings of a thread when threadId is not nil
+func (a *AnalyticsInteractor) Lifecycle(user uuid.UUID, threadId uuid.NullUUID) (core.LifecycleReport, error) {
+	var strings []core.String
+	var err error
+	if threadId.Valid {
+		strings, err = a.StringFinder.FindAllByThread(user, threadId.UUID)
+	} else {
+		strings, err = a.StringFinder.FindAll(user)
+	}
+	if err != nil {
+		return core.LifecycleReport{}, err
+	}
+
+	done, err := a.firstTransitions(user, core.StateDone)
+	if err != nil {
+		return core.LifecycleReport{}, err
+	}
+	dormant, err := a.firstTransitions(user, core.StateDormant)
+	if err != nil {
+		return core.LifecycleReport{}, err
+	}
+
+	report := core.LifecycleReport{
+		Actionable: core.ActionableReport{Counts: map[core.State]int{}},
+		Active:     core.ActiveReport{Counts: map[core.State]int{}},
+	}
+
+	var toDone, toDormant time.Duration
+	var doneCount, dormantCount int
+	for _, cs := range strings {
+		switch cs.Kind {
+		case core.KindActionable:
+			report.Actionable.Counts[cs.State]++
+			report.Actionable.Total++
+			if at, ok := done[cs.Id]; ok && cs.State == core.StateDone {
+				toDone += at.Sub(cs.DateCreated)
+				doneCount++
+			}
+		case core.KindActive:
+			report.Active.Counts[cs.State]++
+			report.Active.Total++
+			if at, ok := dormant[cs.Id]; ok && cs.State == core.StateDormant {
+				toDormant += at.Sub(cs.DateCreated)
+				dormantCount++
+			}
+		}
+	}
+
+	if report.Actionable.Total > 0 {
+		report.Actionable.CompletionRate = float64(report.Actionable.Counts[core.StateDone]) / float64(report.Actionable.Total)
+	}
+	if doneCount > 0 {
+		report.Actionable.AverageDaysToDone = days(toDone) / float64(doneCount)
+	}
+	if report.Active.Total > 0 {
+		faded := report.Active.Counts[core.StateFading] + report.Active.Counts[core.StateDormant]
+		report.Active.FadeRate = float64(faded) / float64(report.Active.Total)
+	}
+	if dormantCount > 0 {
+		report.Active.AverageDaysToDormant = days(toDormant) / float64(dormantCount)
+	}
+
+	return report, nil
+}
+
+// firstTransitions maps each string to the first time it entered a state
+func (a *AnalyticsInteractor) firstTransitions(user uuid.UUID, state core.State) (map[uuid.UUID]time.Time, error) {
+	transitions, err := a.TransitionFinder.FindTransitionsTo(user, state)
+	if err != nil {
+		return nil, err
+	}
+
+	first := make(map[uuid.UUID]time.Time, len(transitions))
+	for _, t := range transitions {
+		if _, ok := first[t.String]; !ok {
+			first[t.String] = t.DateCreated
+		}
+	}
+
+	return first, nil
+}
+
+// priorityDrift turns the revisions of a string into a series of the orders it
+// held in a thread and derives its metrics. A string's order is held until its
+// next revision; time spent deleted or in another thread is not counted.
+func priorityDrift(stringId uuid.UUID, threadId uuid.UUID, revisions []core.StringRevision, now time.Time) core.PriorityDrift {
+	drift := core.PriorityDrift{
+		String: stringId,
+		Thread: threadId,
+		Series: []core.OrderPoint{},
+	}
+
+	type segment struct {
+		order    int
+		duration time.Duration
+	}
+	var segments []segment
+	var current *core.OrderPoint
+
+	closeSegment := func(end time.Time) {
+		if current != nil {
+			segments = append(segments, segment{order: current.Order, duration: end.Sub(current.Date)})
+			current = nil
+		}
+	}
+
+	for _, r := range revisions {
+		inThread := r.After != nil && r.After.Thread == threadId
+		if !inThread {
+			closeSegment(r.DateCreated)
+			continue
+		}
+		if current != nil && current.Order == r.After.Order {
+			continue
+		}
+		closeSegment(r.DateCreated)
+		point := core.OrderPoint{Order: r.After.Order, Date: r.DateCreated}
+		drift.Series = append(drift.Series, point)
+		current = &point
+	}
+	closeSegment(now)
+
+	if len(segments) == 0 {
+		return drift
+	}
+
+	var total time.Duration
+	var weighted float64
+	var inTop time.Duration
+	var longest time.Duration
+	for _, seg := range segments {
+		total += seg.duration
+		weighted += float64(seg.order) * seg.duration.Hours()
+		if seg.order < top {
+			inTop += seg.duration
+		}
+		if seg.duration > longest {
+			longest = seg.duration
+		}
+	}
+
+	if total > 0 {
+		drift.AverageRank = weighted / total.Hours()
+	} else {
+		var sum int
+		for _, seg := range segments {
+			sum += seg.order
+		}
+		drift.AverageRank = float64(sum) / float64(len(segments))
+	}
+	drift.DaysInTop3 = days(inTop)
+	drift.LongestStableDays = days(longest)
+	drift.Volatility = volatility(drift.Series)
+
+	return drift
+}
+
+// volatility is the standard deviation of the change in order between
+// consecutive points of a series
+func volatility(series []core.OrderPoint) float64 {
+	if len(series) < 2 {
+		return 0
+	}
+
+	changes := make([]float64, 0, len(series)-1)
+	var mean float64
+	for i := 1; i < len(series); i++ {
+		change := float64(series[i].Order - series[i-1].Order)
+		changes = append(changes, change)
+		mean += change
+	}
+	mean /= float64(len(changes))
+
+	var variance float64
+	for _, change := range changes {
+		variance += (change - mean) * (change - mean)
+	}
+	variance /= float64(len(changes))
+
+	return math.Sqrt(variance)
+}
+
+func days(d time.Duration) float64 {
+	return d.Hours() / 24
+}
@@ -0,0 +1,47 @@
+package system
+
+import (
+	"errors"
+	"github.com/gofrs/uuid"
+	"github.com/orpheus/strings/core"
+	"github.com/orpheus/strings/infrastructure/logging"
+	"testing"
+)
+
+// noRevisions is a RevisionFinder for users who can not see any revisions
+type noRevisions struct{}
+
+func (noRevisions) FindHistory(user uuid.UUID, stringId uuid.UUID) ([]core.StringRevision, error) {
+	return nil, nil
+}
+
+func (noRevisions) FindHistoryByThread(user uuid.UUID, threadId uuid.UUID) ([]core.StringRevision, error) {
+	return nil, nil
+}
+
+func TestDriftOfIdsTheUserCanNotSeeIsNotFound(t *testing.T) {
+	owner, stranger := uuid.Must(uuid.NewV4()), uuid.Must(uuid.NewV4())
+	store := newMemory()
+	thread := store.addThread(owner, "Run")
+	interactor := &AnalyticsInteractor{
+		RevisionFinder: noRevisions{},
+		Threads:        &memoryThreads{memory: store},
+		Logger:         &logging.TmpLogger{},
+	}
+
+	for name, id := range map[string]uuid.UUID{"unknown": uuid.Must(uuid.NewV4()), "stranger's": thread.Id} {
+		_, err := interactor.StringDrift(stranger, id)
+		if !errors.Is(err, core.ErrStringNotFound) {
+			t.Errorf("StringDrift of an %s id returned %v, want %v", name, err, core.ErrStringNotFound)
+		}
+		_, err = interactor.ThreadDrift(stranger, id)
+		if !errors.Is(err, core.ErrThreadNotFound) {
+			t.Errorf("ThreadDrift of an %s id returned %v, want %v", name, err, core.ErrThreadNotFound)
+		}
+	}
+
+	drifts, err := interactor.ThreadDrift(owner, thread.Id)
+	if err != nil || len(drifts) != 0 {
+		t.Errorf("ThreadDrift of a thread without revisions returned %v, %v, want no drift", drifts, err)
+	}
+}
@@ -0,0 +1,220 @@
+package system
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"github.com/gofrs/uuid"
+	"github.com/orpheus/strings/core"
+	"github.com/orpheus/strings/infrastructure/logging"
+	"golang.org/x/crypto/bcrypt"
+	"strings"
+	"time"
+)
+
+// AuthInteractor registers users, signs them in and resolves the user behind
+// a session or API key
+type AuthInteractor struct {
+	Users      UserRepository
+	Tokens     TokenRepository
+	Transactor Transactor
+	SessionTTL time.Duration
+	Logger     logging.Logger
+}
+
+// TokenRepository stores tokens by their hash, never the tokens themselves
+type TokenRepository interface {
+	CreateOne(token core.Token, hash string) (core.Token, error)
+	FindOwner(hash string) (core.User, error)
+	FindAllByKind(user uuid.UUID, kind core.TokenKind) ([]core.Token, error)
+	RevokeByHash(hash string) error
+	RevokeById(user uuid.UUID, id uuid.UUID) error
+	RevokeAllByKind(user uuid.UUID, kind core.TokenKind) error
+}
+
+const minPasswordLength = 8
+
+// dummyHash is compared against when signing in as a user that does not
+// exist, so that it takes as long as a wrong password
+var dummyHash, _ = bcrypt.GenerateFromPassword([]byte("not a password"), bcrypt.DefaultCost)
+
+// Register creates a user that signs in with a password
+func (a *AuthInteractor) Register(credentials core.Credentials) (core.User, error) {
+	username := strings.TrimSpace(credentials.Username)
+	if username == "" {
+		return core.User{}, core.ErrInvalidName
+	}
+	if len(credentials.Password) < minPasswordLength {
+		return core.User{}, core.ErrWeakPassword
+	}
+
+	_, err := a.Users.FindByUsername(username)
+	if err == nil {
+		return core.User{}, core.ErrUsernameTaken
+	}
+	if err != core.ErrUserNotFound {
+		return core.User{}, err
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(credentials.Password), bcrypt.DefaultCost)
+	if err != nil {
+		return core.User{}, err
+	}
+
+	var created core.User
+	err = a.Transactor.Transact(func(repos Repositories) error {
+		var err error
+		created, err = createUser(repos, core.User{Username: username}, a.Logger)
+		if err != nil {
+			return err
+		}
+		return repos.Users.SetPassword(created.Id, string(hash))
+	})
+	return created, err
+}
+
+// Login checks a user's password and starts a new session
+func (a *AuthInteractor) Login(credentials core.Credentials) (core.Session, error) {
+	user, hash, err := a.Users.FindPasswordHash(strings.TrimSpace(credentials.Username))
+	if err == core.ErrUserNotFound || (err == nil && hash == "") {
+		_ = bcrypt.CompareHashAndPassword(dummyHash, []byte(credentials.Password))
+		return core.Session{}, core.ErrInvalidCredentials
+	}
+	if err != nil {
+		return core.Session{}, err
+	}
+
+	err = bcrypt.CompareHashAndPassword([]byte(hash), []byte(credentials.Password))
+	if err == bcrypt.ErrMismatchedHashAndPassword {
+		return core.Session{}, core.ErrInvalidCredentials
+	}
+	if err != nil {
+		return core.Session{}, err
+	}
+
+	expiresAt := time.Now().Add(a.SessionTTL)
+	_, secret, err := a.issue(core.Token{Owner: user.Id, Kind: core.TokenSession, ExpiresAt: &expiresAt})
+	if err != nil {
+		return core.Session{}, err
+	}
+
+	return core.Session{Token: secret, ExpiresAt: expiresAt, User: user}, nil
+}
+
+// Authenticate returns the user holding a session or API key
+func (a *AuthInteractor) Authenticate(secret string) (core.User, error) {
+	if secret == "" {
+		return core.User{}, core.ErrUnauthenticated
+	}
+	user, err := a.Tokens.FindOwner(hashToken(secret))
+	if err == core.ErrTokenNotFound {
+		return core.User{}, core.ErrUnauthenticated
+	}
+	return user, err
+}
+
+// AuthenticateProxy returns the user a trusted reverse proxy signed in,
+// creating their account on first sight
+func (a *AuthInteractor) AuthenticateProxy(username string) (core.User, error) {
+	username = strings.TrimSpace(username)
+	if username == "" {
+		return core.User{}, core.ErrUnauthenticated
+	}
+
+	user, err := a.Users.FindByUsername(username)
+	if err != core.ErrUserNotFound {
+		return user, err
+	}
+
+	err = a.Transactor.Transact(func(repos Repositories) error {
+		user, err = createUser(repos, core.User{Username: username}, a.Logger)
+		return err
+	})
+	return user, err
+}
+
+// Logout revokes the session or API key used to make a request
+func (a *AuthInteractor) Logout(secret string) error {
+	return a.Tokens.RevokeByHash(hashToken(secret))
+}
+
+// LogoutEverywhere revokes every session of a user. API keys are kept.
+func (a *AuthInteractor) LogoutEverywhere(user uuid.UUID) error {
+	return a.Tokens.RevokeAllByKind(user, core.TokenSession)
+}
+
+// CreateApiKey issues a named API key. The key is only returned here.
+func (a *AuthInteractor) CreateApiKey(user uuid.UUID, name string) (core.ApiKey, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return core.ApiKey{}, core.ErrInvalidName
+	}
+
+	token, secret, err := a.issue(core.Token{Owner: user, Kind: core.TokenApiKey, Name: name})
+	if err != nil {
+		return core.ApiKey{}, err
+	}
+
+	return core.ApiKey{Token: token, Key: secret}, nil
+}
+
+// FindApiKeys lists the API keys of a user that have not been revoked
+func (a *AuthInteractor) FindApiKeys(user uuid.UUID) ([]core.Token, error) {
+	return a.Tokens.FindAllByKind(user, core.TokenApiKey)
+}
+
+func (a *AuthInteractor) RevokeApiKey(user uuid.UUID, id uuid.UUID) error {
+	return a.Tokens.RevokeById(user, id)
+}
+
+// issue saves a new token and returns it along with its secret
+func (a *AuthInteractor) issue(token core.Token) (core.Token, string, error) {
+	secret, err := generateToken()
+	if err != nil {
+		return core.Token{}, "", err
+	}
+	token, err = a.Tokens.CreateOne(token, hashToken(secret))
+	return token, secret, err
+}
+
+// createUser saves a new user. The first user also becomes the owner of the
+// threads and strings created before the service had users. Sign ups take
+// turns, so that two users signing up at once can not both count as the
+// first.
+func createUser(repos Repositories, user core.User, logger logging.Logger) (core.User, error) {
+	err := repos.Users.LockSignUps()
+	if err != nil {
+		return core.User{}, err
+	}
+
+	created, err := repos.Users.CreateOne(user)
+	if err != nil {
+		return core.User{}, err
+	}
+
+	count, err := repos.Users.Count()
+	if err != nil || count > 1 {
+		return created, err
+	}
+
+	logger.Logf("Giving existing threads and strings to %s\n", created.Username)
+	return created, repos.Users.ClaimUnowned(created.Id)
+}
+
+// generateToken returns 32 random bytes, url safe encoded
+func generateToken() (string, error) {
+	b := make([]byte, 32)
+	_, err := rand.Read(b)
+	if err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken is how a token is stored. Tokens are random so a fast hash is
+// enough.
+func hashToken(secret string) string {
+	sum := sha256.Sum256([]byte(secret))
+	return hex.EncodeToString(sum[:])
+}
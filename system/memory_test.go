@@ -0,0 +1,220 @@
+package system
+
+import (
+	"errors"
+	"github.com/gofrs/uuid"
+	"github.com/orpheus/strings/core"
+	"sort"
+	"time"
+)
+
+// errBroken is what the repository method named by memory.failing fails with
+var errBroken = errors.New("the database went away")
+
+// memory keeps threads and strings in maps and implements Transactor the way
+// a database would: the work runs on a copy of the maps, which replaces them
+// only if the work succeeds.
+type memory struct {
+	threads map[uuid.UUID]core.Thread
+	strings map[uuid.UUID]core.String
+	// failing is the repository method that fails, e.g. `Threads.DeleteById`
+	failing string
+}
+
+func newMemory() *memory {
+	return &memory{
+		threads: make(map[uuid.UUID]core.Thread),
+		strings: make(map[uuid.UUID]core.String),
+	}
+}
+
+func (m *memory) Transact(work func(repos Repositories) error) error {
+	tx := &memory{
+		threads: make(map[uuid.UUID]core.Thread, len(m.threads)),
+		strings: make(map[uuid.UUID]core.String, len(m.strings)),
+		failing: m.failing,
+	}
+	for id, t := range m.threads {
+		tx.threads[id] = t
+	}
+	for id, cs := range m.strings {
+		tx.strings[id] = cs
+	}
+
+	err := work(Repositories{Threads: &memoryThreads{memory: tx}, Strings: &memoryStrings{memory: tx}})
+	if err != nil {
+		return err
+	}
+
+	m.threads, m.strings = tx.threads, tx.strings
+	return nil
+}
+
+// addThread saves a thread owned by user with a string for every name
+func (m *memory) addThread(user uuid.UUID, names ...string) core.Thread {
+	thread := core.Thread{Id: uuid.Must(uuid.NewV4()), Name: "Health", Owner: user}
+	m.threads[thread.Id] = thread
+	for i, name := range names {
+		cs := core.String{Id: uuid.Must(uuid.NewV4()), Name: name, Order: i, Thread: thread.Id, Owner: user}
+		m.strings[cs.Id] = cs
+	}
+	return thread
+}
+
+// stringsOf returns the strings of a thread in order, deleted or not
+func (m *memory) stringsOf(threadId uuid.UUID) []core.String {
+	var strings []core.String
+	for _, cs := range m.strings {
+		if cs.Thread == threadId {
+			strings = append(strings, cs)
+		}
+	}
+	sort.Slice(strings, func(i, j int) bool {
+		return strings[i].Order < strings[j].Order
+	})
+	return strings
+}
+
+func (m *memory) fail(method string) error {
+	if m.failing == method {
+		return errBroken
+	}
+	return nil
+}
+
+type memoryThreads struct {
+	// ThreadStore is nil, calling a method that is not implemented below
+	// panics
+	ThreadStore
+	*memory
+}
+
+func (m *memoryThreads) FindRole(user uuid.UUID, id uuid.UUID) (core.Role, error) {
+	t, ok := m.threads[id]
+	if !ok || t.DeletedAt != nil || t.Owner != user {
+		return "", core.ErrThreadNotFound
+	}
+	return core.RoleOwner, nil
+}
+
+func (m *memoryThreads) DeleteById(user uuid.UUID, id uuid.UUID, deletedAt time.Time) error {
+	if err := m.fail("Threads.DeleteById"); err != nil {
+		return err
+	}
+	t := m.threads[id]
+	t.DeletedAt = &deletedAt
+	m.threads[id] = t
+	return nil
+}
+
+func (m *memoryThreads) FindDeletedById(user uuid.UUID, id uuid.UUID) (core.Thread, error) {
+	t, ok := m.threads[id]
+	if !ok || t.DeletedAt == nil || t.Owner != user {
+		return core.Thread{}, core.ErrThreadNotFound
+	}
+	return t, nil
+}
+
+func (m *memoryThreads) RestoreById(user uuid.UUID, id uuid.UUID) (core.Thread, error) {
+	if err := m.fail("Threads.RestoreById"); err != nil {
+		return core.Thread{}, err
+	}
+	t := m.threads[id]
+	t.DeletedAt = nil
+	m.threads[id] = t
+	return t, nil
+}
+
+func (m *memoryThreads) SetPinned(user uuid.UUID, id uuid.UUID, pinned bool) (core.Thread, error) {
+	if err := m.fail("Threads.SetPinned"); err != nil {
+		return core.Thread{}, err
+	}
+	t := m.threads[id]
+	t.Pinned = pinned
+	m.threads[id] = t
+	return t, nil
+}
+
+type memoryStrings struct {
+	// StringStore is nil, calling a method that is not implemented below
+	// panics
+	StringStore
+	*memory
+}
+
+func (m *memoryStrings) DeleteAllByThread(user uuid.UUID, threadId uuid.UUID, deletedAt time.Time) error {
+	if err := m.fail("Strings.DeleteAllByThread"); err != nil {
+		return err
+	}
+	for id, cs := range m.strings {
+		if cs.Thread == threadId && cs.DeletedAt == nil {
+			cs.DeletedAt = &deletedAt
+			m.strings[id] = cs
+		}
+	}
+	return nil
+}
+
+func (m *memoryStrings) RestoreAllByThread(user uuid.UUID, threadId uuid.UUID, deletedAt time.Time) ([]core.String, error) {
+	if err := m.fail("Strings.RestoreAllByThread"); err != nil {
+		return nil, err
+	}
+	var restored []core.String
+	for _, cs := range m.stringsOf(threadId) {
+		if cs.DeletedAt != nil && cs.DeletedAt.Equal(deletedAt) {
+			cs.DeletedAt = nil
+			m.strings[cs.Id] = cs
+			restored = append(restored, cs)
+		}
+	}
+	return restored, nil
+}
+
+func (m *memoryStrings) FindById(user uuid.UUID, id uuid.UUID) (core.String, error) {
+	cs, ok := m.strings[id]
+	if !ok || cs.DeletedAt != nil || cs.Owner != user {
+		return core.String{}, core.ErrStringNotFound
+	}
+	return cs, nil
+}
+
+func (m *memoryStrings) FindByName(user uuid.UUID, threadId uuid.UUID, name string) (core.String, error) {
+	for _, cs := range m.stringsOf(threadId) {
+		if cs.DeletedAt == nil && cs.Owner == user && cs.Name == name {
+			return cs, nil
+		}
+	}
+	return core.String{}, core.ErrStringNotFound
+}
+
+func (m *memoryStrings) FindSubtree(user uuid.UUID, id uuid.UUID) ([]core.String, error) {
+	root, err := m.FindById(user, id)
+	if err != nil {
+		return nil, err
+	}
+	subtree := []core.String{root}
+	for i := 0; i < len(subtree); i++ {
+		for _, cs := range m.stringsOf(subtree[i].Thread) {
+			if cs.DeletedAt == nil && cs.Parent.Valid && cs.Parent.UUID == subtree[i].Id {
+				subtree = append(subtree, cs)
+			}
+		}
+	}
+	return subtree, nil
+}
+
+func (m *memoryStrings) Move(user uuid.UUID, stringId uuid.UUID, threadId uuid.UUID, order int) error {
+	subtree, err := m.FindSubtree(user, stringId)
+	if err != nil {
+		return err
+	}
+	for i, cs := range subtree {
+		cs.Thread = threadId
+		if i == 0 {
+			cs.Parent = uuid.NullUUID{}
+			cs.Order = order
+		}
+		m.strings[cs.Id] = cs
+	}
+	return nil
+}
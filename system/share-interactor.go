@@ -0,0 +1,187 @@
+package system
+
+import (
+	"github.com/gofrs/uuid"
+	"github.com/orpheus/strings/core"
+	"github.com/orpheus/strings/infrastructure/logging"
+	"strings"
+)
+
+// ShareInteractor shares threads with other users. Owners invite users with a
+// role, and the invitees become members once they accept.
+type ShareInteractor struct {
+	Repo       ShareRepository
+	Threads    RoleFinder
+	Transactor Transactor
+	Logger     logging.Logger
+}
+
+type ShareRepository interface {
+	FindMembers(threadId uuid.UUID) ([]core.Member, error)
+	AddMember(threadId uuid.UUID, user uuid.UUID, role core.Role) error
+	SetRole(threadId uuid.UUID, user uuid.UUID, role core.Role) error
+	RemoveMember(threadId uuid.UUID, user uuid.UUID) error
+	CreateInvitation(invitation core.Invitation) (core.Invitation, error)
+	FindPendingInvitation(id uuid.UUID) (core.Invitation, error)
+	FindPendingByInvitee(user uuid.UUID) ([]core.Invitation, error)
+	FindPendingByThread(threadId uuid.UUID) ([]core.Invitation, error)
+	AcceptInvitation(id uuid.UUID) error
+	DeclineInvitation(id uuid.UUID) error
+	DeleteInvitation(id uuid.UUID) error
+}
+
+// FindMembers lists who can see a thread, starting with its owner
+func (s *ShareInteractor) FindMembers(user uuid.UUID, threadId uuid.UUID) ([]core.Member, error) {
+	err := authorize(s.Threads, user, threadId, core.RoleViewer)
+	if err != nil {
+		return nil, err
+	}
+	return s.Repo.FindMembers(threadId)
+}
+
+// SetRole changes what a member may do with a thread
+func (s *ShareInteractor) SetRole(user uuid.UUID, threadId uuid.UUID, member uuid.UUID, role core.Role) error {
+	if !role.Shareable() {
+		return core.ErrInvalidRole
+	}
+	return s.Transactor.Transact(func(repos Repositories) error {
+		err := authorize(repos.Threads, user, threadId, core.RoleOwner)
+		if err != nil {
+			return err
+		}
+		return repos.Shares.SetRole(threadId, member, role)
+	})
+}
+
+// RemoveMember stops sharing a thread with a member. The owner may remove
+// anyone and members may remove themselves.
+func (s *ShareInteractor) RemoveMember(user uuid.UUID, threadId uuid.UUID, member uuid.UUID) error {
+	return s.Transactor.Transact(func(repos Repositories) error {
+		if member != user {
+			err := authorize(repos.Threads, user, threadId, core.RoleOwner)
+			if err != nil {
+				return err
+			}
+		}
+		return repos.Shares.RemoveMember(threadId, member)
+	})
+}
+
+// Invite offers a user a role on a thread. Users who can already see the
+// thread or have a pending invitation to it can not be invited again.
+func (s *ShareInteractor) Invite(user uuid.UUID, threadId uuid.UUID, username string, role core.Role) (core.Invitation, error) {
+	if !role.Shareable() {
+		return core.Invitation{}, core.ErrInvalidRole
+	}
+
+	var created core.Invitation
+	err := s.Transactor.Transact(func(repos Repositories) error {
+		err := authorize(repos.Threads, user, threadId, core.RoleOwner)
+		if err != nil {
+			return err
+		}
+
+		invitee, err := repos.Users.FindByUsername(strings.TrimSpace(username))
+		if err != nil {
+			return err
+		}
+
+		_, err = repos.Threads.FindRole(invitee.Id, threadId)
+		if err == nil {
+			return core.ErrAlreadyMember
+		}
+		if err != core.ErrThreadNotFound {
+			return err
+		}
+
+		pending, err := repos.Shares.FindPendingByThread(threadId)
+		if err != nil {
+			return err
+		}
+		for _, invitation := range pending {
+			if invitation.Invitee == invitee.Id {
+				return core.ErrAlreadyInvited
+			}
+		}
+
+		created, err = repos.Shares.CreateInvitation(core.Invitation{
+			Thread:  threadId,
+			Inviter: user,
+			Invitee: invitee.Id,
+			Role:    role,
+		})
+		return err
+	})
+	return created, err
+}
+
+// FindInvitations lists the pending invitations to a thread
+func (s *ShareInteractor) FindInvitations(user uuid.UUID, threadId uuid.UUID) ([]core.Invitation, error) {
+	err := authorize(s.Threads, user, threadId, core.RoleOwner)
+	if err != nil {
+		return nil, err
+	}
+	return s.Repo.FindPendingByThread(threadId)
+}
+
+// FindReceivedInvitations lists the pending invitations sent to a user
+func (s *ShareInteractor) FindReceivedInvitations(user uuid.UUID) ([]core.Invitation, error) {
+	return s.Repo.FindPendingByInvitee(user)
+}
+
+// Accept makes the invitee a member of the thread with the role they were
+// invited with
+func (s *ShareInteractor) Accept(user uuid.UUID, id uuid.UUID) error {
+	return s.Transactor.Transact(func(repos Repositories) error {
+		invitation, err := findReceived(repos.Shares, user, id)
+		if err != nil {
+			return err
+		}
+		err = repos.Shares.AcceptInvitation(id)
+		if err != nil {
+			return err
+		}
+		return repos.Shares.AddMember(invitation.Thread, user, invitation.Role)
+	})
+}
+
+func (s *ShareInteractor) Decline(user uuid.UUID, id uuid.UUID) error {
+	return s.Transactor.Transact(func(repos Repositories) error {
+		_, err := findReceived(repos.Shares, user, id)
+		if err != nil {
+			return err
+		}
+		return repos.Shares.DeclineInvitation(id)
+	})
+}
+
+// Revoke withdraws a pending invitation. Only the owner of the thread may.
+func (s *ShareInteractor) Revoke(user uuid.UUID, id uuid.UUID) error {
+	return s.Transactor.Transact(func(repos Repositories) error {
+		invitation, err := repos.Shares.FindPendingInvitation(id)
+		if err != nil {
+			return err
+		}
+		err = authorize(repos.Threads, user, invitation.Thread, core.RoleOwner)
+		if err == core.ErrThreadNotFound {
+			return core.ErrInvitationNotFound
+		}
+		if err != nil {
+			return err
+		}
+		return repos.Shares.DeleteInvitation(id)
+	})
+}
+
+// findReceived fetches a pending invitation sent to a user. Invitations sent
+// to others are not found.
+func findReceived(repo ShareRepository, user uuid.UUID, id uuid.UUID) (core.Invitation, error) {
+	invitation, err := repo.FindPendingInvitation(id)
+	if err != nil {
+		return core.Invitation{}, err
+	}
+	if invitation.Invitee != user {
+		return core.Invitation{}, core.ErrInvitationNotFound
+	}
+	return invitation, nil
+}
@@ -0,0 +1,208 @@
+package system
+
+import (
+	"github.com/gofrs/uuid"
+	"github.com/orpheus/strings/core"
+	"github.com/orpheus/strings/infrastructure/logging"
+	"sort"
+	"time"
+)
+
+type SnapshotInteractor struct {
+	ThreadHistory ThreadHistory
+	StringHistory StringHistory
+	Logger        logging.Logger
+}
+
+// ThreadHistory reconstructs the threads of a user from their recorded
+// revisions
+type ThreadHistory interface {
+	FindAllAt(user uuid.UUID, at time.Time) ([]core.Thread, error)
+}
+
+// StringHistory reconstructs the strings of a user from their recorded
+// revisions
+type StringHistory interface {
+	FindAllAt(user uuid.UUID, at time.Time) ([]core.String, error)
+}
+
+// Snapshot returns every thread and string of a user as they were at the
+// given instant.
+// Threads are sorted like the thread list and strings by thread, each followed
+// by its minor strings, so that two snapshots of the same data always
+// serialize the same way.
+func (s *SnapshotInteractor) Snapshot(user uuid.UUID, at time.Time) (core.Snapshot, error) {
+	threads, err := s.ThreadHistory.FindAllAt(user, at)
+	if err != nil {
+		return core.Snapshot{}, err
+	}
+
+	strings, err := s.StringHistory.FindAllAt(user, at)
+	if err != nil {
+		return core.Snapshot{}, err
+	}
+
+	sort.SliceStable(threads, func(i, j int) bool {
+		if threads[i].Pinned != threads[j].Pinned {
+			return threads[i].Pinned
+		}
+		if threads[i].Order != threads[j].Order {
+			return threads[i].Order < threads[j].Order
+		}
+		return threads[i].DateCreated.Before(threads[j].DateCreated)
+	})
+
+	return core.Snapshot{
+		At:      at,
+		Threads: threads,
+		Strings: outline(strings),
+	}, nil
+}
+
+// outline sorts strings by thread and lays out each thread like its tree:
+// every string comes right before its minor strings, and siblings come in
+// order. Strings whose parent is not among them are taken as top level.
+func outline(strings []core.String) []core.String {
+	ids := make(map[uuid.UUID]bool, len(strings))
+	for _, cs := range strings {
+		ids[cs.Id] = true
+	}
+
+	var roots []core.String
+	children := make(map[uuid.UUID][]core.String)
+	for _, cs := range strings {
+		if cs.Parent.Valid && ids[cs.Parent.UUID] {
+			children[cs.Parent.UUID] = append(children[cs.Parent.UUID], cs)
+		} else {
+			roots = append(roots, cs)
+		}
+	}
+
+	bySiblingOrder := func(siblings []core.String) {
+		sort.Slice(siblings, func(i, j int) bool {
+			if siblings[i].Thread != siblings[j].Thread {
+				return siblings[i].Thread.String() < siblings[j].Thread.String()
+			}
+			if siblings[i].Order != siblings[j].Order {
+				return siblings[i].Order < siblings[j].Order
+			}
+			return siblings[i].Id.String() < siblings[j].Id.String()
+		})
+	}
+
+	sorted := make([]core.String, 0, len(strings))
+	var visit func(cs core.String)
+	visit = func(cs core.String) {
+		sorted = append(sorted, cs)
+		bySiblingOrder(children[cs.Id])
+		for _, child := range children[cs.Id] {
+			visit(child)
+		}
+	}
+	bySiblingOrder(roots)
+	for _, cs := range roots {
+		visit(cs)
+	}
+
+	return sorted
+}
+
+// Diff compares the snapshots of a user taken at `from` and `to` and reports
+// what changed between them
+func (s *SnapshotInteractor) Diff(user uuid.UUID, from time.Time, to time.Time) (core.Diff, error) {
+	before, err := s.Snapshot(user, from)
+	if err != nil {
+		return core.Diff{}, err
+	}
+
+	after, err := s.Snapshot(user, to)
+	if err != nil {
+		return core.Diff{}, err
+	}
+
+	return diffSnapshots(before, after), nil
+}
+
+func diffSnapshots(before core.Snapshot, after core.Snapshot) core.Diff {
+	diff := core.Diff{
+		From:           before.At,
+		To:             after.At,
+		ThreadsAdded:   []core.Thread{},
+		ThreadsRemoved: []core.Thread{},
+		StringsAdded:   []core.String{},
+		StringsRemoved: []core.String{},
+		StringsRenamed: []core.StringRename{},
+		StringsMoved:   []core.StringMove{},
+		Reordered:      []core.ThreadReorder{},
+	}
+
+	threadsBefore := make(map[uuid.UUID]core.Thread, len(before.Threads))
+	for _, t := range before.Threads {
+		threadsBefore[t.Id] = t
+	}
+	threadsAfter := make(map[uuid.UUID]core.Thread, len(after.Threads))
+	for _, t := range after.Threads {
+		threadsAfter[t.Id] = t
+		if _, ok := threadsBefore[t.Id]; !ok {
+			diff.ThreadsAdded = append(diff.ThreadsAdded, t)
+		}
+	}
+	for _, t := range before.Threads {
+		if _, ok := threadsAfter[t.Id]; !ok {
+			diff.ThreadsRemoved = append(diff.ThreadsRemoved, t)
+		}
+	}
+
+	stringsBefore := make(map[uuid.UUID]core.String, len(before.Strings))
+	for _, cs := range before.Strings {
+		stringsBefore[cs.Id] = cs
+	}
+	stringsAfter := make(map[uuid.UUID]core.String, len(after.Strings))
+	for _, cs := range after.Strings {
+		stringsAfter[cs.Id] = cs
+	}
+	for _, cs := range before.Strings {
+		if _, ok := stringsAfter[cs.Id]; !ok {
+			diff.StringsRemoved = append(diff.StringsRemoved, cs)
+		}
+	}
+
+	// after.Strings is sorted by thread and outline, so reorders are
+	// collected per thread in a stable order
+	reorders := make(map[uuid.UUID]int)
+	for _, cs := range after.Strings {
+		prev, ok := stringsBefore[cs.Id]
+		if !ok {
+			diff.StringsAdded = append(diff.StringsAdded, cs)
+			continue
+		}
+		if prev.Name != cs.Name {
+			diff.StringsRenamed = append(diff.StringsRenamed, core.StringRename{
+				Id:   cs.Id,
+				From: prev.Name,
+				To:   cs.Name,
+			})
+		}
+		if prev.Thread != cs.Thread {
+			diff.StringsMoved = append(diff.StringsMoved, core.StringMove{
+				Id:         cs.Id,
+				FromThread: prev.Thread,
+				ToThread:   cs.Thread,
+			})
+			continue
+		}
+		if prev.Order != cs.Order {
+			i, ok := reorders[cs.Thread]
+			if !ok {
+				diff.Reordered = append(diff.Reordered, core.ThreadReorder{Thread: cs.Thread})
+				i = len(diff.Reordered) - 1
+				reorders[cs.Thread] = i
+			}
+			reorder := &diff.Reordered[i]
+			reorder.Before = append(reorder.Before, core.StringOrder{Id: cs.Id, Order: prev.Order})
+			reorder.After = append(reorder.After, core.StringOrder{Id: cs.Id, Order: cs.Order})
+		}
+	}
+
+	return diff
+}
@@ -0,0 +1,59 @@
+package system
+
+import (
+	"github.com/gofrs/uuid"
+	"github.com/orpheus/strings/core"
+	"github.com/orpheus/strings/infrastructure/logging"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// threadHistory and stringHistory always have the same threads and strings
+type threadHistory []core.Thread
+
+func (h threadHistory) FindAllAt(user uuid.UUID, at time.Time) ([]core.Thread, error) {
+	return h, nil
+}
+
+type stringHistory []core.String
+
+func (h stringHistory) FindAllAt(user uuid.UUID, at time.Time) ([]core.String, error) {
+	return h, nil
+}
+
+func TestSnapshotPutsMinorStringsUnderTheirParents(t *testing.T) {
+	thread := uuid.Must(uuid.NewV4())
+	named := func(name string, order int, parent *core.String) core.String {
+		cs := core.String{Id: uuid.Must(uuid.NewV4()), Name: name, Order: order, Thread: thread}
+		if parent != nil {
+			cs.Parent = uuid.NullUUID{UUID: parent.Id, Valid: true}
+		}
+		return cs
+	}
+	run := named("Run", 0, nil)
+	swim := named("Swim", 1, nil)
+	stretch := named("Stretch", 0, &run)
+	warmUp := named("Warm up", 1, &run)
+	dive := named("Dive", 0, &swim)
+	breathe := named("Breathe", 0, &dive)
+	interactor := &SnapshotInteractor{
+		ThreadHistory: threadHistory{},
+		StringHistory: stringHistory{breathe, dive, warmUp, swim, stretch, run},
+		Logger:        &logging.TmpLogger{},
+	}
+
+	snapshot, err := interactor.Snapshot(uuid.Must(uuid.NewV4()), time.Now())
+	if err != nil {
+		t.Fatalf("Snapshot failed: %s", err)
+	}
+
+	want := []string{"Run", "Stretch", "Warm up", "Swim", "Dive", "Breathe"}
+	var got []string
+	for _, cs := range snapshot.Strings {
+		got = append(got, cs.Name)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("the snapshot has strings %q, want %q", got, want)
+	}
+}
@@ -1,45 +1,591 @@
 package system
 
 import (
+	"errors"
+	"fmt"
 	"github.com/gofrs/uuid"
 	"github.com/orpheus/strings/core"
 	"github.com/orpheus/strings/infrastructure/logging"
+	"strings"
+	"time"
 )
 
+// StringInteractor validates and applies changes to strings. Changes that
+// read before they write run as a single unit of work through the Transactor.
+// Anyone who can see a string can read it but only editors and the owner of
+// its thread can change it.
 type StringInteractor struct {
 	StringRepository StringRepository
+	Transactor       Transactor
 	Logger           logging.Logger
 }
 
+// StringRepository reads and writes the strings of a user. Every method takes
+// the user making the change and only sees the strings of threads that user
+// owns or that were shared with them.
 type StringRepository interface {
-	FindAll() ([]core.String, error)
-	FindAllByThread(threadId uuid.UUID) ([]core.String, error)
-	CreateOne(core.String) (core.String, error)
-	DeleteById(id uuid.UUID) error
-	UpdateName(stringId uuid.UUID, name string) error
-	UpdateOrder(stringOrders []core.StringOrder) error
+	FindAll(user uuid.UUID) ([]core.String, error)
+	FindAllByThread(user uuid.UUID, threadId uuid.UUID) ([]core.String, error)
+	CreateOne(user uuid.UUID, cs core.String) (core.String, error)
+	DeleteById(user uuid.UUID, id uuid.UUID) error
+	Update(user uuid.UUID, stringId uuid.UUID, patch core.StringPatch) (core.String, error)
+	UpdateOrder(user uuid.UUID, stringOrders []core.StringOrder) error
+	FindHistory(user uuid.UUID, stringId uuid.UUID) ([]core.StringRevision, error)
+	FindById(user uuid.UUID, id uuid.UUID) (core.String, error)
+	FindByName(user uuid.UUID, threadId uuid.UUID, name string) (core.String, error)
+	UpdateState(user uuid.UUID, stringId uuid.UUID, from core.State, to core.State) error
+	FindTransitions(user uuid.UUID, stringId uuid.UUID) ([]core.StateTransition, error)
+	FindSubtree(user uuid.UUID, id uuid.UUID) ([]core.String, error)
+	FindAncestorIds(user uuid.UUID, id uuid.UUID) ([]uuid.UUID, error)
+	UpdateParent(user uuid.UUID, stringId uuid.UUID, parent uuid.NullUUID) error
+	Move(user uuid.UUID, stringId uuid.UUID, threadId uuid.UUID, order int) error
+	Reposition(user uuid.UUID, stringId uuid.UUID, after uuid.NullUUID, before uuid.NullUUID) error
+	Rebalance(siblings core.Siblings) error
+	FindCrowded(maxLength int) ([]core.Siblings, error)
 }
 
-func (s *StringInteractor) FindAll() ([]core.String, error) {
-	return s.StringRepository.FindAll()
+func (s *StringInteractor) FindAll(user uuid.UUID) ([]core.String, error) {
+	return s.StringRepository.FindAll(user)
 }
 
-func (s *StringInteractor) FindAllByThread(threadId uuid.UUID) ([]core.String, error) {
-	return s.StringRepository.FindAllByThread(threadId)
+func (s *StringInteractor) FindAllByThread(user uuid.UUID, threadId uuid.UUID) ([]core.String, error) {
+	return s.StringRepository.FindAllByThread(user, threadId)
 }
 
-func (s *StringInteractor) CreateOne(string core.String) (core.String, error) {
-	return s.StringRepository.CreateOne(string)
+// CreateOne creates a string in a thread the user can edit, in the initial
+// state of its kind and after the strings already there. Strings without a
+// kind take the kind of their thread. A parent, if given, must be in the same
+// thread. The name must not be taken by another string of the thread.
+func (s *StringInteractor) CreateOne(user uuid.UUID, string core.String) (core.String, error) {
+	return s.create(user, string, false)
 }
 
-func (s *StringInteractor) DeleteById(id uuid.UUID) error {
-	return s.StringRepository.DeleteById(id)
+// FindOrCreate creates a string like CreateOne, unless the thread already has
+// a string with its name. That string is returned as it is instead.
+func (s *StringInteractor) FindOrCreate(user uuid.UUID, string core.String) (core.String, error) {
+	created, err := s.create(user, string, true)
+	if errors.Is(err, core.ErrStringNameTaken) {
+		// someone else created it first
+		return s.StringRepository.FindByName(user, string.Thread, strings.TrimSpace(string.Name))
+	}
+	return created, err
 }
 
-func (s *StringInteractor) UpdateName(stringId uuid.UUID, name string) error {
-	return s.StringRepository.UpdateName(stringId, name)
+func (s *StringInteractor) create(user uuid.UUID, string core.String, merge bool) (core.String, error) {
+	string.Name = strings.TrimSpace(string.Name)
+	if string.Name == "" {
+		return core.String{}, core.ErrInvalidName
+	}
+
+	var created core.String
+	err := s.Transactor.Transact(func(repos Repositories) error {
+		thread, err := repos.Threads.FindById(user, string.Thread)
+		if err != nil {
+			return err
+		}
+		err = authorize(repos.Threads, user, thread.Id, core.RoleEditor)
+		if err != nil {
+			return err
+		}
+
+		existing, err := repos.Strings.FindByName(user, string.Thread, string.Name)
+		if err == nil && merge {
+			created = existing
+			return nil
+		}
+		if err == nil {
+			return core.ErrStringNameTaken
+		}
+		if err != core.ErrStringNotFound {
+			return err
+		}
+
+		if string.Kind == "" {
+			string.Kind = thread.Kind
+		}
+		if !string.Kind.Valid() {
+			return core.ErrInvalidKind
+		}
+		string.State = string.Kind.InitialState()
+
+		if string.Parent.Valid {
+			parent, err := repos.Strings.FindById(user, string.Parent.UUID)
+			if err != nil {
+				return err
+			}
+			if parent.Thread != string.Thread {
+				return core.ErrParentThread
+			}
+		}
+
+		created, err = repos.Strings.CreateOne(user, string)
+		return err
+	})
+	return created, err
+}
+
+// DeleteById moves a string and its minor strings to the trash. Strings the
+// user can not see are not found.
+func (s *StringInteractor) DeleteById(user uuid.UUID, id uuid.UUID) error {
+	return s.Transactor.Transact(func(repos Repositories) error {
+		err := authorizeString(repos, user, id, core.RoleEditor)
+		if err != nil {
+			return err
+		}
+		return repos.Strings.DeleteById(user, id)
+	})
+}
+
+func (s *StringInteractor) UpdateName(user uuid.UUID, stringId uuid.UUID, name string) error {
+	_, err := s.Update(user, stringId, core.StringPatch{Name: &name})
+	return err
+}
+
+func (s *StringInteractor) UpdateDescription(user uuid.UUID, stringId uuid.UUID, description string) error {
+	_, err := s.Update(user, stringId, core.StringPatch{Description: &description})
+	return err
+}
+
+// Update validates and applies a patch to a string. Names are trimmed, must
+// not be blank and must not be taken in the thread the string ends up in,
+// which goes for the names of its minor strings too when it moves. Orders must not be negative and the user must be able to edit both the
+// thread of the string and the new thread, if any.
+func (s *StringInteractor) Update(user uuid.UUID, stringId uuid.UUID, patch core.StringPatch) (core.String, error) {
+	if patch.Name != nil {
+		name := strings.TrimSpace(*patch.Name)
+		if name == "" {
+			return core.String{}, core.ErrInvalidName
+		}
+		patch.Name = &name
+	}
+
+	if patch.Order != nil && *patch.Order < 0 {
+		return core.String{}, core.ErrInvalidOrder
+	}
+
+	var updated core.String
+	err := s.Transactor.Transact(func(repos Repositories) error {
+		err := authorizeString(repos, user, stringId, core.RoleEditor)
+		if err != nil {
+			return err
+		}
+		if patch.Thread != nil {
+			err = authorize(repos.Threads, user, *patch.Thread, core.RoleEditor)
+			if err != nil {
+				return err
+			}
+		}
+
+		if patch.Name != nil || patch.Thread != nil {
+			cs, err := repos.Strings.FindById(user, stringId)
+			if err != nil {
+				return err
+			}
+			if patch.Name != nil {
+				cs.Name = *patch.Name
+			}
+			if patch.Thread != nil {
+				cs.Thread = *patch.Thread
+			}
+			err = checkName(repos, user, cs)
+			if err != nil {
+				return err
+			}
+		}
+		if patch.Thread != nil {
+			err = checkMinorNames(repos, user, stringId, *patch.Thread)
+			if err != nil {
+				return err
+			}
+		}
+
+		updated, err = repos.Strings.Update(user, stringId, patch)
+		return err
+	})
+	return updated, err
+}
+
+// UpdateOrder saves a new order for a group of sibling strings, which editors
+// may do too. Every string of the group must be given exactly once and the
+// orders must run from 0 without gaps or repeats. Otherwise nothing is saved
+// and ErrStringOrders lists what is wrong with each entry.
+func (s *StringInteractor) UpdateOrder(user uuid.UUID, stringOrders []core.StringOrder) error {
+	if len(stringOrders) == 0 {
+		return core.ErrStringOrders
+	}
+
+	return s.Transactor.Transact(func(repos Repositories) error {
+		var problems []core.OrderProblem
+		reject := func(id uuid.UUID, problem string, args ...interface{}) {
+			problems = append(problems, core.OrderProblem{Id: id.String(), Problem: fmt.Sprintf(problem, args...)})
+		}
+
+		var first *core.String
+		given := make(map[uuid.UUID]bool)
+		taken := make(map[int]uuid.UUID)
+		for _, stringOrder := range stringOrders {
+			cs, err := repos.Strings.FindById(user, stringOrder.Id)
+			if err == core.ErrStringNotFound {
+				reject(stringOrder.Id, "string not found")
+				continue
+			}
+			if err != nil {
+				return err
+			}
+
+			if first == nil {
+				first = &cs
+				err = authorize(repos.Threads, user, cs.Thread, core.RoleEditor)
+				if err != nil {
+					return err
+				}
+			} else if cs.Thread != first.Thread {
+				reject(cs.Id, "not in the same thread as %s", first.Id)
+			} else if cs.Parent != first.Parent {
+				reject(cs.Id, "not nested under the same string as %s", first.Id)
+			}
+
+			if given[cs.Id] {
+				reject(cs.Id, "given more than once")
+			}
+			given[cs.Id] = true
+
+			if stringOrder.Order < 0 || stringOrder.Order >= len(stringOrders) {
+				reject(cs.Id, "order must be between 0 and %d", len(stringOrders)-1)
+			} else if other, ok := taken[stringOrder.Order]; ok {
+				reject(cs.Id, "order %d is also given to %s", stringOrder.Order, other)
+			} else {
+				taken[stringOrder.Order] = cs.Id
+			}
+		}
+
+		if first != nil {
+			siblings, err := repos.Strings.FindAllByThread(user, first.Thread)
+			if err != nil {
+				return err
+			}
+			for _, sibling := range siblings {
+				if sibling.Parent == first.Parent && !given[sibling.Id] {
+					reject(sibling.Id, "missing, every string among the siblings must be given an order")
+				}
+			}
+		}
+
+		if len(problems) > 0 {
+			return core.ErrStringOrders.WithDetails(problems)
+		}
+
+		return repos.Strings.UpdateOrder(user, stringOrders)
+	})
+}
+
+// FindHistory fetches every revision of a string, oldest first. Every string
+// has at least the revision of its creation, so a string without any is one
+// the user can not see.
+func (s *StringInteractor) FindHistory(user uuid.UUID, stringId uuid.UUID) ([]core.StringRevision, error) {
+	history, err := s.StringRepository.FindHistory(user, stringId)
+	if err != nil {
+		return nil, err
+	}
+	if len(history) == 0 {
+		return nil, core.ErrStringNotFound
+	}
+	return history, nil
+}
+
+// Transition moves a string to the next state of its lifecycle. Only the
+// transitions allowed by its kind are accepted.
+func (s *StringInteractor) Transition(user uuid.UUID, stringId uuid.UUID, to core.State) (core.String, error) {
+	var transitioned core.String
+	err := s.Transactor.Transact(func(repos Repositories) error {
+		cs, err := repos.Strings.FindById(user, stringId)
+		if err != nil {
+			return err
+		}
+		err = authorize(repos.Threads, user, cs.Thread, core.RoleEditor)
+		if err != nil {
+			return err
+		}
+
+		if !core.CanTransition(cs.State, to) {
+			return fmt.Errorf("%w: %s -> %s", core.ErrInvalidTransition, cs.State, to)
+		}
+
+		err = repos.Strings.UpdateState(user, stringId, cs.State, to)
+		if err != nil {
+			return err
+		}
+
+		transitioned, err = repos.Strings.FindById(user, stringId)
+		return err
+	})
+	return transitioned, err
+}
+
+func (s *StringInteractor) FindTransitions(user uuid.UUID, stringId uuid.UUID) ([]core.StateTransition, error) {
+	return s.StringRepository.FindTransitions(user, stringId)
+}
+
+// FindTree returns a string with all of its minor strings nested under it,
+// each level sorted by order
+func (s *StringInteractor) FindTree(user uuid.UUID, id uuid.UUID) (core.StringNode, error) {
+	subtree, err := s.StringRepository.FindSubtree(user, id)
+	if err != nil {
+		return core.StringNode{}, err
+	}
+	if len(subtree) == 0 {
+		return core.StringNode{}, core.ErrStringNotFound
+	}
+
+	children := make(map[uuid.UUID][]core.String)
+	var root core.String
+	for _, cs := range subtree {
+		if cs.Id == id {
+			root = cs
+			continue
+		}
+		children[cs.Parent.UUID] = append(children[cs.Parent.UUID], cs)
+	}
+
+	var build func(cs core.String) core.StringNode
+	build = func(cs core.String) core.StringNode {
+		node := core.StringNode{String: cs, Children: []core.StringNode{}}
+		for _, child := range children[cs.Id] {
+			node.Children = append(node.Children, build(child))
+		}
+		return node
+	}
+
+	return build(root), nil
+}
+
+// Reparent nests a string and its minor strings under another string of the
+// same thread, or moves it to the top level of its thread if parent is null.
+// A string can never end up nested under itself.
+func (s *StringInteractor) Reparent(user uuid.UUID, stringId uuid.UUID, parent uuid.NullUUID) (core.String, error) {
+	var reparented core.String
+	err := s.Transactor.Transact(func(repos Repositories) error {
+		cs, err := repos.Strings.FindById(user, stringId)
+		if err != nil {
+			return err
+		}
+		err = authorize(repos.Threads, user, cs.Thread, core.RoleEditor)
+		if err != nil {
+			return err
+		}
+
+		if parent.Valid {
+			p, err := repos.Strings.FindById(user, parent.UUID)
+			if err != nil {
+				return err
+			}
+			if p.Thread != cs.Thread {
+				return core.ErrParentThread
+			}
+
+			ancestors, err := repos.Strings.FindAncestorIds(user, parent.UUID)
+			if err != nil {
+				return err
+			}
+			for _, ancestor := range ancestors {
+				if ancestor == stringId {
+					return core.ErrStringCycle
+				}
+			}
+		}
+
+		err = repos.Strings.UpdateParent(user, stringId, parent)
+		if err != nil {
+			return err
+		}
+
+		reparented, err = repos.Strings.FindById(user, stringId)
+		return err
+	})
+	return reparented, err
+}
+
+// Move moves a string, along with its minor strings, to the top level of a
+// thread at the given order, keeping its id, history and creation date. The
+// user must be able to edit both threads and the names of the string and its
+// minor strings must not be taken in the thread.
+func (s *StringInteractor) Move(user uuid.UUID, stringId uuid.UUID, threadId uuid.UUID, order int) (core.String, error) {
+	if order < 0 {
+		return core.String{}, core.ErrInvalidOrder
+	}
+
+	var moved core.String
+	err := s.Transactor.Transact(func(repos Repositories) error {
+		err := authorizeString(repos, user, stringId, core.RoleEditor)
+		if err != nil {
+			return err
+		}
+		err = authorize(repos.Threads, user, threadId, core.RoleEditor)
+		if err != nil {
+			return err
+		}
+
+		cs, err := repos.Strings.FindById(user, stringId)
+		if err != nil {
+			return err
+		}
+		cs.Thread = threadId
+		err = checkName(repos, user, cs)
+		if err != nil {
+			return err
+		}
+		err = checkMinorNames(repos, user, stringId, threadId)
+		if err != nil {
+			return err
+		}
+
+		err = repos.Strings.Move(user, stringId, threadId, order)
+		if err != nil {
+			return err
+		}
+
+		moved, err = repos.Strings.FindById(user, stringId)
+		return err
+	})
+	return moved, err
+}
+
+// Reposition puts a string right after the string after, right before the
+// string before, or between the two. Usually only the rank of the string is
+// updated, but when there is no room left between the neighbors the ranks of
+// all its siblings are spread out first. The neighbors must be siblings of the
+// string and after must come before before.
+func (s *StringInteractor) Reposition(user uuid.UUID, stringId uuid.UUID, after uuid.NullUUID, before uuid.NullUUID) (core.String, error) {
+	if !after.Valid && !before.Valid {
+		return core.String{}, core.ErrReposition
+	}
+	if (after.Valid && after.UUID == stringId) || (before.Valid && before.UUID == stringId) {
+		return core.String{}, core.ErrReposition
+	}
+
+	var repositioned core.String
+	err := s.Transactor.Transact(func(repos Repositories) error {
+		cs, err := repos.Strings.FindById(user, stringId)
+		if err != nil {
+			return err
+		}
+		err = authorize(repos.Threads, user, cs.Thread, core.RoleEditor)
+		if err != nil {
+			return err
+		}
+
+		var neighbors []core.String
+		for _, id := range []uuid.NullUUID{after, before} {
+			if !id.Valid {
+				continue
+			}
+			neighbor, err := repos.Strings.FindById(user, id.UUID)
+			if err != nil {
+				return err
+			}
+			if neighbor.Thread != cs.Thread || neighbor.Parent != cs.Parent {
+				return core.ErrNotSiblings
+			}
+			neighbors = append(neighbors, neighbor)
+		}
+		if len(neighbors) == 2 && neighbors[0].Order >= neighbors[1].Order {
+			return core.ErrNeighbors
+		}
+
+		err = repos.Strings.Reposition(user, stringId, after, before)
+		if err != nil {
+			return err
+		}
+
+		repositioned, err = repos.Strings.FindById(user, stringId)
+		return err
+	})
+	return repositioned, err
+}
+
+// Rebalance spreads out the ranks of every group of siblings whose ranks have
+// grown past core.MaxRankLength from repeated inserts in the same place
+func (s *StringInteractor) Rebalance() error {
+	crowded, err := s.StringRepository.FindCrowded(core.MaxRankLength)
+	if err != nil {
+		return err
+	}
+
+	for _, siblings := range crowded {
+		err = s.StringRepository.Rebalance(siblings)
+		if err != nil {
+			return err
+		}
+	}
+	if len(crowded) > 0 {
+		s.Logger.Logf("Rebalanced the ranks of %d groups of strings\n", len(crowded))
+	}
+
+	return nil
+}
+
+// RunRebalance rebalances crowded ranks every interval until stop is closed
+func (s *StringInteractor) RunRebalance(interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		err := s.Rebalance()
+		if err != nil {
+			s.Logger.Logf("Failed to rebalance string ranks: %s\n", err.Error())
+		}
+
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
+
+// checkName returns ErrStringNameTaken if another string of the thread of cs
+// has its name
+func checkName(repos Repositories, user uuid.UUID, cs core.String) error {
+	existing, err := repos.Strings.FindByName(user, cs.Thread, cs.Name)
+	if err == core.ErrStringNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if existing.Id != cs.Id {
+		return core.ErrStringNameTaken
+	}
+	return nil
+}
+
+// checkMinorNames returns ErrStringNameTaken if a string of a thread has the
+// name of one of the minor strings nested under a string, which move to that
+// thread along with it
+func checkMinorNames(repos Repositories, user uuid.UUID, stringId uuid.UUID, threadId uuid.UUID) error {
+	subtree, err := repos.Strings.FindSubtree(user, stringId)
+	if err != nil {
+		return err
+	}
+	for _, cs := range subtree {
+		if cs.Id == stringId {
+			continue
+		}
+		cs.Thread = threadId
+		err = checkName(repos, user, cs)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
 
-func (s *StringInteractor) UpdateOrder(stringOrders []core.StringOrder) error {
-	return s.StringRepository.UpdateOrder(stringOrders)
+// authorizeString checks that a user has at least the required role on the
+// thread of a string
+func authorizeString(repos Repositories, user uuid.UUID, stringId uuid.UUID, required core.Role) error {
+	cs, err := repos.Strings.FindById(user, stringId)
+	if err != nil {
+		return err
+	}
+	return authorize(repos.Threads, user, cs.Thread, required)
 }
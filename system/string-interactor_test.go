@@ -0,0 +1,70 @@
+package system
+
+import (
+	"errors"
+	"github.com/gofrs/uuid"
+	"github.com/orpheus/strings/core"
+	"github.com/orpheus/strings/infrastructure/logging"
+	"testing"
+)
+
+// nest saves a string named name under parent, in the thread of parent
+func nest(store *memory, parent core.String, name string) core.String {
+	cs := core.String{
+		Id:     uuid.Must(uuid.NewV4()),
+		Name:   name,
+		Thread: parent.Thread,
+		Owner:  parent.Owner,
+		Parent: uuid.NullUUID{UUID: parent.Id, Valid: true},
+	}
+	store.strings[cs.Id] = cs
+	return cs
+}
+
+func TestMoveRefusesThreadsWithTheNameOfAMinorString(t *testing.T) {
+	user := uuid.Must(uuid.NewV4())
+	store := newMemory()
+	from := store.addThread(user, "Run")
+	to := store.addThread(user, "Warm up")
+	run := store.stringsOf(from.Id)[0]
+	nest(store, nest(store, run, "Stretch"), "Warm up")
+	interactor := &StringInteractor{Transactor: store, Logger: &logging.TmpLogger{}}
+
+	for _, move := range []func() error{
+		func() error {
+			_, err := interactor.Move(user, run.Id, to.Id, 0)
+			return err
+		},
+		func() error {
+			_, err := interactor.Update(user, run.Id, core.StringPatch{Thread: &to.Id})
+			return err
+		},
+	} {
+		err := move()
+		if !errors.Is(err, core.ErrStringNameTaken) {
+			t.Errorf("moving the string returned %v, want %v", err, core.ErrStringNameTaken)
+		}
+		if left := len(store.stringsOf(from.Id)); left != 3 {
+			t.Errorf("%d of 3 strings are left in the thread, want all of them", left)
+		}
+	}
+}
+
+func TestMoveTakesTheMinorStringsAlong(t *testing.T) {
+	user := uuid.Must(uuid.NewV4())
+	store := newMemory()
+	from := store.addThread(user, "Run")
+	to := store.addThread(user, "Swim")
+	run := store.stringsOf(from.Id)[0]
+	stretch := nest(store, run, "Stretch")
+	interactor := &StringInteractor{Transactor: store, Logger: &logging.TmpLogger{}}
+
+	_, err := interactor.Move(user, run.Id, to.Id, 0)
+	if err != nil {
+		t.Fatalf("Move failed: %s", err)
+	}
+
+	if moved := store.strings[stretch.Id]; moved.Thread != to.Id || moved.Parent != stretch.Parent {
+		t.Errorf("the minor string ended up in %s under %v, want %s under %v", moved.Thread, moved.Parent, to.Id, stretch.Parent)
+	}
+}
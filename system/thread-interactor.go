@@ -4,38 +4,141 @@ import (
 	"github.com/gofrs/uuid"
 	"github.com/orpheus/strings/core"
 	"github.com/orpheus/strings/infrastructure/logging"
+	"time"
 )
 
 type ThreadInteractor struct {
-	Repo          ThreadRepository
-	StringDeleter StringDeleter
-	Logger        logging.Logger
+	Repo         ThreadRepository
+	StringFinder StringFinder
+	Transactor   Transactor
+	Logger       logging.Logger
 }
 
+// ThreadRepository reads and writes the threads of a user. Every method takes
+// the user making the change and only sees the threads that user owns or that
+// were shared with them. Whether they may change them is up to the interactor.
 type ThreadRepository interface {
-	FindAll() ([]core.Thread, error)
-	CreateOne(thread core.Thread) (core.Thread, error)
-	DeleteById(id uuid.UUID) error
+	RoleFinder
+	FindAll(user uuid.UUID, archived bool) ([]core.Thread, error)
+	FindById(user uuid.UUID, id uuid.UUID) (core.Thread, error)
+	CreateOne(user uuid.UUID, thread core.Thread) (core.Thread, error)
+	Update(user uuid.UUID, thread core.Thread) (core.Thread, error)
+	UpdateOrder(user uuid.UUID, threadOrders []core.ThreadOrder) error
+	SetPinned(user uuid.UUID, id uuid.UUID, pinned bool) (core.Thread, error)
+	SetArchived(user uuid.UUID, id uuid.UUID, archived bool) (core.Thread, error)
+	DeleteById(user uuid.UUID, id uuid.UUID, deletedAt time.Time) error
 }
 
 type StringDeleter interface {
-	DeleteAllByThread(threadId uuid.UUID) error
+	DeleteAllByThread(user uuid.UUID, threadId uuid.UUID, deletedAt time.Time) error
 }
 
-func (t *ThreadInteractor) FindAll() ([]core.Thread, error) {
-	return t.Repo.FindAll()
+// FindAll fetches the active threads of a user, or the archived threads if
+// archived is true
+func (t *ThreadInteractor) FindAll(user uuid.UUID, archived bool) ([]core.Thread, error) {
+	return t.Repo.FindAll(user, archived)
 }
 
-func (t *ThreadInteractor) CreateOne(thread core.Thread) (core.Thread, error) {
-	return t.Repo.CreateOne(thread)
+// CreateOne creates a thread, which is actionable unless a kind is given
+func (t *ThreadInteractor) CreateOne(user uuid.UUID, thread core.Thread) (core.Thread, error) {
+	if thread.Kind == "" {
+		thread.Kind = core.KindActionable
+	}
+	if !thread.Kind.Valid() {
+		return core.Thread{}, core.ErrInvalidKind
+	}
+	return t.Repo.CreateOne(user, thread)
 }
 
-// DeleteById deletes all the strings associated with a thread and then deletes
-// the thread itself.
-func (t *ThreadInteractor) DeleteById(id uuid.UUID) error {
-	err := t.StringDeleter.DeleteAllByThread(id)
+// FindById fetches a thread together with its strings in order
+func (t *ThreadInteractor) FindById(user uuid.UUID, id uuid.UUID) (core.Thread, error) {
+	thread, err := t.Repo.FindById(user, id)
 	if err != nil {
-		return err
+		return core.Thread{}, err
+	}
+
+	thread.Strings, err = t.StringFinder.FindAllByThread(user, id)
+	if err != nil {
+		return core.Thread{}, err
 	}
-	return t.Repo.DeleteById(id)
+
+	return thread, nil
+}
+
+// Update renames and re-describes a thread. Editors may do this too.
+func (t *ThreadInteractor) Update(user uuid.UUID, thread core.Thread) (core.Thread, error) {
+	var updated core.Thread
+	err := t.Transactor.Transact(func(repos Repositories) error {
+		err := authorize(repos.Threads, user, thread.Id, core.RoleEditor)
+		if err != nil {
+			return err
+		}
+
+		updated, err = repos.Threads.Update(user, thread)
+		return err
+	})
+	return updated, err
+}
+
+// UpdateOrder saves the order of threads. The order, pin and archive flags of
+// a thread are the same for everyone who can see it, so only its owner may
+// change them. Threads the user can not see are skipped.
+func (t *ThreadInteractor) UpdateOrder(user uuid.UUID, threadOrders []core.ThreadOrder) error {
+	return t.Transactor.Transact(func(repos Repositories) error {
+		for _, threadOrder := range threadOrders {
+			err := authorize(repos.Threads, user, threadOrder.Id, core.RoleOwner)
+			if err != nil && err != core.ErrThreadNotFound {
+				return err
+			}
+		}
+		return repos.Threads.UpdateOrder(user, threadOrders)
+	})
+}
+
+func (t *ThreadInteractor) SetPinned(user uuid.UUID, id uuid.UUID, pinned bool) (core.Thread, error) {
+	var updated core.Thread
+	err := t.Transactor.Transact(func(repos Repositories) error {
+		err := authorize(repos.Threads, user, id, core.RoleOwner)
+		if err != nil {
+			return err
+		}
+
+		updated, err = repos.Threads.SetPinned(user, id, pinned)
+		return err
+	})
+	return updated, err
+}
+
+func (t *ThreadInteractor) SetArchived(user uuid.UUID, id uuid.UUID, archived bool) (core.Thread, error) {
+	var updated core.Thread
+	err := t.Transactor.Transact(func(repos Repositories) error {
+		err := authorize(repos.Threads, user, id, core.RoleOwner)
+		if err != nil {
+			return err
+		}
+
+		updated, err = repos.Threads.SetArchived(user, id, archived)
+		return err
+	})
+	return updated, err
+}
+
+// DeleteById moves all the strings associated with a thread to the trash and
+// then the thread itself, in one transaction. Both are marked with the same
+// deletion time so that restoring the thread brings back exactly the strings
+// deleted with it. Only the owner may delete a thread.
+func (t *ThreadInteractor) DeleteById(user uuid.UUID, id uuid.UUID) error {
+	deletedAt := time.Now()
+	return t.Transactor.Transact(func(repos Repositories) error {
+		err := authorize(repos.Threads, user, id, core.RoleOwner)
+		if err != nil {
+			return err
+		}
+
+		err = repos.Strings.DeleteAllByThread(user, id, deletedAt)
+		if err != nil {
+			return err
+		}
+		return repos.Threads.DeleteById(user, id, deletedAt)
+	})
 }
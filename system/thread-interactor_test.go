@@ -0,0 +1,71 @@
+package system
+
+import (
+	"errors"
+	"github.com/gofrs/uuid"
+	"github.com/orpheus/strings/core"
+	"github.com/orpheus/strings/infrastructure/logging"
+	"reflect"
+	"testing"
+)
+
+func TestDeleteByIdMovesTheThreadAndItsStringsToTheTrash(t *testing.T) {
+	user := uuid.Must(uuid.NewV4())
+	store := newMemory()
+	thread := store.addThread(user, "Run", "Stretch")
+	interactor := &ThreadInteractor{Transactor: store, Logger: &logging.TmpLogger{}}
+
+	err := interactor.DeleteById(user, thread.Id)
+	if err != nil {
+		t.Fatalf("DeleteById failed: %s", err)
+	}
+
+	deletedAt := store.threads[thread.Id].DeletedAt
+	if deletedAt == nil {
+		t.Fatal("the thread is not in the trash")
+	}
+	for _, cs := range store.stringsOf(thread.Id) {
+		if cs.DeletedAt == nil || !cs.DeletedAt.Equal(*deletedAt) {
+			t.Errorf("string %q was not deleted along with its thread", cs.Name)
+		}
+	}
+}
+
+func TestDeleteByIdChangesNothingWhenTheThreadFailsToDelete(t *testing.T) {
+	user := uuid.Must(uuid.NewV4())
+	store := newMemory()
+	thread := store.addThread(user, "Run", "Stretch")
+	store.failing = "Threads.DeleteById"
+	before := store.stringsOf(thread.Id)
+	interactor := &ThreadInteractor{Transactor: store, Logger: &logging.TmpLogger{}}
+
+	err := interactor.DeleteById(user, thread.Id)
+	if !errors.Is(err, errBroken) {
+		t.Fatalf("DeleteById returned %v, want %v", err, errBroken)
+	}
+
+	if store.threads[thread.Id].DeletedAt != nil {
+		t.Error("the thread went to the trash")
+	}
+	if after := store.stringsOf(thread.Id); !reflect.DeepEqual(after, before) {
+		t.Errorf("the strings changed although the thread was not deleted\nbefore: %+v\nafter:  %+v", before, after)
+	}
+}
+
+func TestSetPinnedOnlyPinsThreadsOfTheOwner(t *testing.T) {
+	owner, stranger := uuid.Must(uuid.NewV4()), uuid.Must(uuid.NewV4())
+	store := newMemory()
+	thread := store.addThread(owner, "Run")
+	// without a Repo, any check made outside of the transaction panics
+	interactor := &ThreadInteractor{Transactor: store, Logger: &logging.TmpLogger{}}
+
+	_, err := interactor.SetPinned(stranger, thread.Id, true)
+	if !errors.Is(err, core.ErrThreadNotFound) || store.threads[thread.Id].Pinned {
+		t.Errorf("SetPinned by a stranger returned %v and pinned %t, want %v and not pinned", err, store.threads[thread.Id].Pinned, core.ErrThreadNotFound)
+	}
+
+	pinned, err := interactor.SetPinned(owner, thread.Id, true)
+	if err != nil || !pinned.Pinned || !store.threads[thread.Id].Pinned {
+		t.Errorf("SetPinned by the owner returned %+v, %v, want the thread pinned", pinned, err)
+	}
+}
@@ -0,0 +1,27 @@
+package system
+
+// Transactor runs a unit of work inside a single database transaction. The
+// repositories handed to the work all share that transaction, so either every
+// change they make is committed or, if the work returns an error, none are.
+type Transactor interface {
+	Transact(work func(repos Repositories) error) error
+}
+
+// Repositories are the repositories available to a unit of work
+type Repositories struct {
+	Threads ThreadStore
+	Strings StringStore
+	Users   UserRepository
+	Shares  ShareRepository
+}
+
+type ThreadStore interface {
+	ThreadRepository
+	ThreadTrash
+}
+
+type StringStore interface {
+	StringRepository
+	StringDeleter
+	StringTrash
+}
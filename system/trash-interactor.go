@@ -0,0 +1,143 @@
+package system
+
+import (
+	"github.com/gofrs/uuid"
+	"github.com/orpheus/strings/core"
+	"github.com/orpheus/strings/infrastructure/logging"
+	"time"
+)
+
+type TrashInteractor struct {
+	ThreadTrash ThreadTrash
+	StringTrash StringTrash
+	Transactor  Transactor
+	Logger      logging.Logger
+}
+
+// ThreadTrash lists and restores the deleted threads of a user. Purge applies
+// to every user.
+type ThreadTrash interface {
+	FindDeleted(user uuid.UUID) ([]core.Thread, error)
+	FindDeletedById(user uuid.UUID, id uuid.UUID) (core.Thread, error)
+	RestoreById(user uuid.UUID, id uuid.UUID) (core.Thread, error)
+	Purge(before time.Time) (int64, error)
+}
+
+// StringTrash lists and restores the deleted strings of a user. Purge applies
+// to every user.
+type StringTrash interface {
+	FindDeleted(user uuid.UUID) ([]core.String, error)
+	RestoreById(user uuid.UUID, id uuid.UUID) ([]core.String, error)
+	RestoreAllByThread(user uuid.UUID, threadId uuid.UUID, deletedAt time.Time) ([]core.String, error)
+	Purge(before time.Time) (int64, error)
+}
+
+// FindAll lists the trash of a user. Strings deleted along with their thread
+// are listed under that thread rather than on their own.
+func (t *TrashInteractor) FindAll(user uuid.UUID) (core.Trash, error) {
+	threads, err := t.ThreadTrash.FindDeleted(user)
+	if err != nil {
+		return core.Trash{}, err
+	}
+
+	strings, err := t.StringTrash.FindDeleted(user)
+	if err != nil {
+		return core.Trash{}, err
+	}
+
+	deletedWith := make(map[uuid.UUID]int, len(threads))
+	for i := range threads {
+		deletedWith[threads[i].Id] = i
+		threads[i].Strings = []core.String{}
+	}
+
+	trash := core.Trash{Threads: threads, Strings: []core.String{}}
+	for _, cs := range strings {
+		i, ok := deletedWith[cs.Thread]
+		if ok && threads[i].DeletedAt.Equal(*cs.DeletedAt) {
+			threads[i].Strings = append(threads[i].Strings, cs)
+			continue
+		}
+		trash.Strings = append(trash.Strings, cs)
+	}
+
+	return trash, nil
+}
+
+// Restore takes a thread or a string out of the trash. A thread comes back
+// with the strings that were deleted along with it in their original order,
+// a string with its minor strings.
+func (t *TrashInteractor) Restore(user uuid.UUID, id uuid.UUID) (core.Trash, error) {
+	restored := core.Trash{Threads: []core.Thread{}, Strings: []core.String{}}
+	err := t.Transactor.Transact(func(repos Repositories) error {
+		thread, err := repos.Threads.FindDeletedById(user, id)
+		if err == core.ErrThreadNotFound {
+			restored.Strings, err = repos.Strings.RestoreById(user, id)
+			return err
+		}
+		if err != nil {
+			return err
+		}
+
+		// the restored thread no longer has the time it was deleted at, which
+		// is how the strings deleted along with it are found
+		restoredThread, err := repos.Threads.RestoreById(user, id)
+		if err != nil {
+			return err
+		}
+
+		restoredThread.Strings, err = repos.Strings.RestoreAllByThread(user, id, *thread.DeletedAt)
+		if err != nil {
+			return err
+		}
+
+		restored.Threads = append(restored.Threads, restoredThread)
+		return nil
+	})
+	return restored, err
+}
+
+// Purge permanently deletes everything that went to the trash before the
+// given time
+func (t *TrashInteractor) Purge(before time.Time) error {
+	var strings, threads int64
+	err := t.Transactor.Transact(func(repos Repositories) error {
+		var err error
+		strings, err = repos.Strings.Purge(before)
+		if err != nil {
+			return err
+		}
+
+		threads, err = repos.Threads.Purge(before)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if strings > 0 || threads > 0 {
+		t.Logger.Logf("Purged %d threads and %d strings from the trash\n", threads, strings)
+	}
+
+	return nil
+}
+
+// RunPurge purges everything older than retention from the trash every
+// interval until stop is closed
+func (t *TrashInteractor) RunPurge(retention time.Duration, interval time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		err := t.Purge(time.Now().Add(-retention))
+		if err != nil {
+			t.Logger.Logf("Failed to purge trash: %s\n", err.Error())
+		}
+
+		select {
+		case <-ticker.C:
+		case <-stop:
+			return
+		}
+	}
+}
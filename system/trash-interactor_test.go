@@ -0,0 +1,78 @@
+package system
+
+import (
+	"errors"
+	"github.com/gofrs/uuid"
+	"github.com/orpheus/strings/infrastructure/logging"
+	"reflect"
+	"testing"
+)
+
+// deletedThread saves a thread with two strings and moves it to the trash
+func deletedThread(t *testing.T, user uuid.UUID, store *memory) uuid.UUID {
+	thread := store.addThread(user, "Run", "Stretch")
+	interactor := &ThreadInteractor{Transactor: store, Logger: &logging.TmpLogger{}}
+	err := interactor.DeleteById(user, thread.Id)
+	if err != nil {
+		t.Fatalf("DeleteById failed: %s", err)
+	}
+	return thread.Id
+}
+
+func TestRestoreBringsBackAThreadWithItsStrings(t *testing.T) {
+	user := uuid.Must(uuid.NewV4())
+	store := newMemory()
+	threadId := deletedThread(t, user, store)
+	interactor := &TrashInteractor{Transactor: store, Logger: &logging.TmpLogger{}}
+
+	restored, err := interactor.Restore(user, threadId)
+	if err != nil {
+		t.Fatalf("Restore failed: %s", err)
+	}
+
+	if len(restored.Threads) != 1 || restored.Threads[0].Id != threadId {
+		t.Fatalf("Restore returned threads %+v, want the restored thread", restored.Threads)
+	}
+	if restored.Threads[0].DeletedAt != nil {
+		t.Error("the restored thread is still marked as deleted")
+	}
+	var names []string
+	for _, cs := range restored.Threads[0].Strings {
+		names = append(names, cs.Name)
+	}
+	if !reflect.DeepEqual(names, []string{"Run", "Stretch"}) {
+		t.Errorf("the thread came back with strings %q, want the strings deleted along with it", names)
+	}
+
+	if store.threads[threadId].DeletedAt != nil {
+		t.Error("the thread is still in the trash")
+	}
+	for _, cs := range store.stringsOf(threadId) {
+		if cs.DeletedAt != nil {
+			t.Errorf("string %q is still in the trash", cs.Name)
+		}
+	}
+}
+
+func TestRestoreChangesNothingWhenTheStringsFailToRestore(t *testing.T) {
+	user := uuid.Must(uuid.NewV4())
+	store := newMemory()
+	threadId := deletedThread(t, user, store)
+	store.failing = "Strings.RestoreAllByThread"
+	before := store.threads[threadId]
+	interactor := &TrashInteractor{Transactor: store, Logger: &logging.TmpLogger{}}
+
+	_, err := interactor.Restore(user, threadId)
+	if !errors.Is(err, errBroken) {
+		t.Fatalf("Restore returned %v, want %v", err, errBroken)
+	}
+
+	if after := store.threads[threadId]; !reflect.DeepEqual(after, before) {
+		t.Errorf("the thread changed although its strings were not restored\nbefore: %+v\nafter:  %+v", before, after)
+	}
+	for _, cs := range store.stringsOf(threadId) {
+		if cs.DeletedAt == nil {
+			t.Errorf("string %q left the trash", cs.Name)
+		}
+	}
+}
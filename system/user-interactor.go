@@ -0,0 +1,27 @@
+package system
+
+import (
+	"github.com/gofrs/uuid"
+	"github.com/orpheus/strings/core"
+	"github.com/orpheus/strings/infrastructure/logging"
+)
+
+type UserInteractor struct {
+	Repo   UserRepository
+	Logger logging.Logger
+}
+
+type UserRepository interface {
+	FindById(id uuid.UUID) (core.User, error)
+	FindByUsername(username string) (core.User, error)
+	FindPasswordHash(username string) (core.User, string, error)
+	CreateOne(user core.User) (core.User, error)
+	SetPassword(id uuid.UUID, hash string) error
+	Count() (int, error)
+	LockSignUps() error
+	ClaimUnowned(id uuid.UUID) error
+}
+
+func (u *UserInteractor) FindById(id uuid.UUID) (core.User, error) {
+	return u.Repo.FindById(id)
+}